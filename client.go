@@ -0,0 +1,776 @@
+package railflush
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAPIURL is the Railway GraphQL endpoint used unless overridden.
+const defaultAPIURL = "https://backboard.railway.com/graphql/v2"
+
+// errTruncatedResponse marks a response body that ended early, because the
+// connection broke off mid-body or the JSON stops short. Requesting it again
+// usually gets all of it, so it is retried.
+var errTruncatedResponse = errors.New("truncated response")
+
+// errUnexpectedEndOfJSON is the message of the *json.SyntaxError that
+// json.Unmarshal returns for input that ends early. Trailing junk also fails at
+// the end of the input, so the offset alone does not tell the two apart.
+const errUnexpectedEndOfJSON = "unexpected end of JSON input"
+
+// maxErrorBody caps how much of a non-200 response body is included in errors.
+const maxErrorBody = 1 << 10
+
+// graphqlRequest represents a GraphQL request body.
+type graphqlRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+// graphqlResponse represents a raw GraphQL response.
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphqlError  `json:"errors"`
+}
+
+// graphqlError is a single entry of a GraphQL response's errors array.
+type graphqlError struct {
+	Message    string         `json:"message"`
+	Path       []any          `json:"path"`
+	Extensions map[string]any `json:"extensions"`
+}
+
+// String formats the error message followed by its path and extensions, if any.
+func (e graphqlError) String() string {
+	var details []string
+	if len(e.Path) > 0 {
+		parts := make([]string, len(e.Path))
+		for i, p := range e.Path {
+			parts[i] = fmt.Sprint(p)
+		}
+		details = append(details, "path "+strings.Join(parts, "."))
+	}
+	if len(e.Extensions) > 0 {
+		if ext, err := json.Marshal(e.Extensions); err == nil {
+			details = append(details, "extensions "+string(ext))
+		}
+	}
+	if len(details) == 0 {
+		return e.Message
+	}
+	return e.Message + " (" + strings.Join(details, ", ") + ")"
+}
+
+// graphqlErrors is the errors array of a GraphQL response, as a single error.
+type graphqlErrors []graphqlError
+
+func (errs graphqlErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.String()
+	}
+	if len(msgs) == 1 {
+		return fmt.Sprintf("graphql error: %s", msgs[0])
+	}
+	return fmt.Sprintf("%d graphql errors: %s", len(msgs), strings.Join(msgs, "; "))
+}
+
+// deploymentsData represents the response from the deployments query.
+type deploymentsData struct {
+	Deployments deploymentConnection `json:"deployments"`
+}
+
+// deploymentConnection is a page of deployments.
+type deploymentConnection struct {
+	Edges []struct {
+		Node Deployment `json:"node"`
+	} `json:"edges"`
+}
+
+// nodes returns the page's deployments, newest first. They are sorted by
+// createdAt rather than relying on the API's ordering.
+func (d deploymentConnection) nodes() []Deployment {
+	deployments := make([]Deployment, len(d.Edges))
+	for i, edge := range d.Edges {
+		deployments[i] = edge.Node
+	}
+	slices.SortStableFunc(deployments, func(a, b Deployment) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return deployments
+}
+
+// ErrNoDeployment is matched (with errors.Is) when a service has no deployment
+// matching the query.
+var ErrNoDeployment = errors.New("no deployment found")
+
+// ErrNoPreviousDeployment is matched (with errors.Is) by -rollback failures of
+// services with only one matching deployment.
+var ErrNoPreviousDeployment = errors.New("no previous deployment to roll back to")
+
+// Deployment is a single Railway deployment.
+type Deployment struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	// UpdatedAt is only set by the single deployment query -wait uses.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// inProgressStatuses are deployment statuses of a deployment that is still
+// being built or rolled out.
+var inProgressStatuses = []string{"BUILDING", "DEPLOYING", "INITIALIZING", "QUEUED", "WAITING"}
+
+// DeploymentInProgressError reports a service whose newest deployment is still
+// in progress, so it has no deployment to act on yet.
+type DeploymentInProgressError struct {
+	DeploymentID string
+	Status       string
+}
+
+func (e *DeploymentInProgressError) Error() string {
+	return fmt.Sprintf("deployment %s in progress (status %s), skipping", e.DeploymentID, e.Status)
+}
+
+// DeploymentUnhealthyError reports a service whose current deployment is not
+// healthy, so -require-healthy-before-restart leaves it alone.
+type DeploymentUnhealthyError struct {
+	DeploymentID string
+	Status       string
+}
+
+func (e *DeploymentUnhealthyError) Error() string {
+	return fmt.Sprintf("current deployment %s has status %s, which is not healthy; leaving the service alone", e.DeploymentID, e.Status)
+}
+
+// Client talks to the Railway GraphQL API.
+type Client struct {
+	httpClient       *http.Client
+	endpoint         string
+	token            string
+	retry            RetryPolicy
+	userAgent        string
+	requireHTTP2     bool
+	tokenType        string
+	warnedHTTP1      sync.Once
+	deploymentsLimit int
+}
+
+// DefaultDeploymentsLimit is how many deployments are fetched per service
+// unless -deployments-limit changes it.
+const DefaultDeploymentsLimit = 10
+
+// NewClient returns a Client that sends requests to endpoint through
+// httpClient, authenticating with token and retrying according to retry.
+// Requests carry DefaultUserAgent until SetUserAgent changes it.
+func NewClient(httpClient *http.Client, endpoint, token string, retry RetryPolicy) *Client {
+	return &Client{httpClient: httpClient, endpoint: endpoint, token: token, retry: retry, userAgent: DefaultUserAgent(), deploymentsLimit: DefaultDeploymentsLimit}
+}
+
+// SetDeploymentsLimit sets how many of a service's deployments Deployments
+// fetches; the newest is picked by createdAt whatever the limit.
+func (c *Client) SetDeploymentsLimit(n int) {
+	c.deploymentsLimit = n
+}
+
+// SetRequireHTTP2 makes the client warn when the API answers over a protocol
+// other than HTTP/2. Failing the request instead could report a restart that
+// was applied as failed.
+func (c *Client) SetRequireHTTP2(require bool) {
+	c.requireHTTP2 = require
+}
+
+// SetTokenType sets how the token is sent: as a bearer token for
+// TokenTypeAccount, the default, or in a Project-Access-Token header for
+// TokenTypeProject.
+func (c *Client) SetTokenType(tokenType string) {
+	c.tokenType = tokenType
+}
+
+// SetUserAgent sets the User-Agent header sent with every request.
+func (c *Client) SetUserAgent(ua string) {
+	c.userAgent = ua
+}
+
+// do sends a GraphQL request to the Railway API and returns the parsed response,
+// retrying transient failures according to the client's retry policy.
+func (c *Client) do(ctx context.Context, query string, variables map[string]any) (*graphqlResponse, error) {
+	return c.request(ctx, c.retry, query, variables)
+}
+
+// mutate sends a GraphQL mutation. Unlike queries, a mutation whose response
+// was lost may already have taken effect, so retrying it can apply it twice;
+// mutations are therefore retried at most ActionRetries times.
+func (c *Client) mutate(ctx context.Context, mutation string, variables map[string]any) (*graphqlResponse, error) {
+	policy := c.retry
+	policy.MaxRetries = policy.ActionRetries
+	return c.request(ctx, policy, mutation, variables)
+}
+
+// request sends a GraphQL request, retrying transient failures according to policy.
+func (c *Client) request(ctx context.Context, policy RetryPolicy, query string, variables map[string]any) (*graphqlResponse, error) {
+	body, err := json.Marshal(graphqlRequest{
+		Query:     query,
+		Variables: variables,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	ctx, span := startSpan(ctx, graphqlOperation(query), spanKindClient, stringAttr("url.full", c.endpoint))
+	if id, ok := variables["serviceId"].(string); ok {
+		span.setAttr("railway.service.id", id)
+	}
+	if id, ok := variables["id"].(string); ok {
+		span.setAttr("railway.deployment.id", id)
+	}
+
+	// Every attempt of a logical call carries the same request ID, so retries
+	// can be correlated server-side.
+	requestID := newRequestID()
+	span.setAttr("http.request.header.x-request-id", requestID)
+
+	var gqlResp *graphqlResponse
+	attempt := 0
+	start := time.Now()
+	err = withRetry(ctx, policy, func() error {
+		if err := policy.Limiter.wait(ctx); err != nil {
+			return fmt.Errorf("waiting for rate limit: %w", err)
+		}
+		attempt++
+		gqlResp, err = c.send(ctx, body, requestID, attempt)
+		return err
+	}, "request_id", requestID)
+	elapsed := time.Since(start)
+	slog.Debug(fmt.Sprintf("GraphQL %s took %s (%d attempt(s))", graphqlOperation(query), elapsed.Round(time.Millisecond), attempt), Icon("⏱️"),
+		"operation", graphqlOperation(query), "request_id", requestID, "attempts", attempt, "duration", elapsed)
+	err = explainAccessError(err, variables, c.tokenType)
+	span.end(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return gqlResp, nil
+}
+
+// accessDeniedCodes are the extensions.code values of the GraphQL errors
+// Railway returns when a token is valid but not allowed to see or change a
+// resource.
+var accessDeniedCodes = []string{"FORBIDDEN", "UNAUTHORIZED", "UNAUTHENTICATED"}
+
+// accessDeniedMessages are the whole messages, compared case-insensitively, of
+// such errors when they carry no code. Matching whole messages keeps errors
+// that merely mention a permission, such as a field named permissions, from
+// being taken for access errors.
+var accessDeniedMessages = []string{"not authorized", "unauthorized", "forbidden", "access denied", "permission denied"}
+
+// accessDenied reports whether e is an access error by its code or message.
+func (e graphqlError) accessDenied() bool {
+	if code, ok := e.Extensions["code"].(string); ok && slices.Contains(accessDeniedCodes, strings.ToUpper(code)) {
+		return true
+	}
+	msg := strings.TrimRight(strings.TrimSpace(e.Message), ".!")
+	return slices.ContainsFunc(accessDeniedMessages, func(m string) bool { return strings.EqualFold(msg, m) })
+}
+
+// ErrAuth is matched (with errors.Is) by errors caused by a rejected or
+// insufficiently scoped API token.
+var ErrAuth = errors.New("authentication failed")
+
+// authError is an access error with guidance. Its message is unchanged, but it
+// matches both ErrAuth and the underlying error.
+type authError struct {
+	msg string
+	err error
+}
+
+func (e *authError) Error() string   { return e.msg }
+func (e *authError) Unwrap() []error { return []error{ErrAuth, e.err} }
+
+// explainAccessError adds actionable guidance to errors caused by a rejected
+// or insufficiently scoped token, naming the resource from variables. A
+// rejected token may also have been sent as the wrong tokenType.
+func explainAccessError(err error, variables map[string]any, tokenType string) error {
+	if err == nil {
+		return nil
+	}
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusUnauthorized {
+		hint := "project tokens need -token-type project"
+		if tokenType == TokenTypeProject {
+			hint = "it was sent as a project token; account and team tokens need -token-type account"
+		}
+		return &authError{msg: fmt.Sprintf("API token was rejected; check that it is valid and has not expired (%s): %v", hint, err), err: err}
+	}
+	var gqlErrs graphqlErrors
+	denied := (se != nil && se.StatusCode == http.StatusForbidden) ||
+		(errors.As(err, &gqlErrs) && slices.ContainsFunc(gqlErrs, graphqlError.accessDenied))
+	if !denied {
+		return err
+	}
+
+	resource := "the requested resource"
+	switch {
+	case variables["projectId"] != nil:
+		resource = fmt.Sprintf("project %s", variables["projectId"])
+	case variables["serviceId"] != nil:
+		resource = fmt.Sprintf("service %s", variables["serviceId"])
+	case variables["id"] != nil:
+		resource = fmt.Sprintf("deployment %s", variables["id"])
+	}
+	return &authError{msg: fmt.Sprintf("token lacks access to %s; check its team/project scope (a project token only works for its own project and environment): %v", resource, err), err: err}
+}
+
+// newRequestID returns a random (version 4) UUID identifying a logical API call.
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// graphqlOperation names a GraphQL document by its operation type and first
+// field, e.g. "mutation deploymentRestart". Batched queries repeat the field
+// under aliases and are named after it all the same.
+func graphqlOperation(query string) string {
+	op, rest, _ := strings.Cut(strings.TrimSpace(query), " ")
+	if _, rest, ok := strings.Cut(rest, "{"); ok {
+		fields := strings.FieldsFunc(rest, func(r rune) bool { return r == '(' || r == '{' || r == ' ' || r == '\n' })
+		// Skip an alias, as in "s0: deployments(...)".
+		if len(fields) > 1 && strings.HasSuffix(fields[0], ":") {
+			fields = fields[1:]
+		}
+		if len(fields) > 0 {
+			return op + " " + fields[0]
+		}
+	}
+	return op
+}
+
+// connTrace logs which connection each request uses and, for new
+// connections, the protocol negotiated on it.
+func connTrace(requestID string) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				slog.Debug(fmt.Sprintf("Request %s reuses connection to %s (idle %s)", requestID, info.Conn.RemoteAddr(), info.IdleTime.Round(time.Millisecond)), Icon("🔌"),
+					"request_id", requestID, "remote_addr", info.Conn.RemoteAddr().String(), "reused", true)
+				return
+			}
+			protocol := "http/1.1"
+			if tc, ok := info.Conn.(*tls.Conn); ok {
+				if p := tc.ConnectionState().NegotiatedProtocol; p != "" {
+					protocol = p
+				}
+			}
+			slog.Debug(fmt.Sprintf("Request %s opened a new connection to %s (%s)", requestID, info.Conn.RemoteAddr(), protocol), Icon("🔌"),
+				"request_id", requestID, "remote_addr", info.Conn.RemoteAddr().String(), "reused", false, "protocol", protocol)
+		},
+	}
+}
+
+// requestTimeoutKey is the context key for withRequestTimeout.
+type requestTimeoutKey struct{}
+
+// withRequestTimeout makes each API request sent with ctx time out after d,
+// for per-service timeouts. The http.Client's own Timeout still applies, so
+// it is left unset when this is used.
+func withRequestTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey{}, d)
+}
+
+// send performs a single GraphQL HTTP round trip.
+func (c *Client) send(ctx context.Context, body []byte, requestID string, attempt int) (*graphqlResponse, error) {
+	parent := ctx
+	timeout, perRequest := ctx.Value(requestTimeoutKey{}).(time.Duration)
+	if perRequest {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.tokenType == TokenTypeProject {
+		req.Header.Set("Project-Access-Token", c.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("X-Request-Id", requestID)
+	req.Header.Set("User-Agent", c.userAgent)
+	// Asking for gzip here, rather than leaving it to http.Transport, keeps
+	// responses compressed whatever RoundTripper the http.Client has; they are
+	// decoded below.
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	auth := "Authorization: Bearer"
+	if c.tokenType == TokenTypeProject {
+		auth = "Project-Access-Token:"
+	}
+	slog.Debug(fmt.Sprintf("GraphQL request %s (attempt %d) to %s (%s [REDACTED]): %s", requestID, attempt, c.endpoint, auth, body), Icon("🐛"),
+		"endpoint", c.endpoint, "request_id", requestID, "attempt", attempt, "body", string(body))
+
+	if slog.Default().Enabled(ctx, slog.LevelDebug) {
+		req = req.WithContext(httptrace.WithClientTrace(ctx, connTrace(requestID)))
+	}
+
+	sent := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if perRequest && ctx.Err() != nil && parent.Err() == nil {
+			return nil, fmt.Errorf("sending request: %w (timed out after %s)", err, timeout)
+		}
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	if c.requireHTTP2 && resp.ProtoMajor != 2 {
+		c.warnedHTTP1.Do(func() {
+			slog.Warn(fmt.Sprintf("-http2: %s answered over %s, not HTTP/2", c.endpoint, resp.Proto), Icon("⚠️"),
+				"endpoint", c.endpoint, "protocol", resp.Proto)
+		})
+	}
+	elapsed := time.Since(sent)
+	slog.Log(ctx, levelTrace, fmt.Sprintf("GraphQL request took %s (status %d)", elapsed.Round(time.Millisecond), resp.StatusCode), Icon("⏱️"),
+		"status", resp.StatusCode, "duration", elapsed)
+
+	respBody := io.Reader(resp.Body)
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(resp.Body)
+		switch {
+		case err == nil:
+			defer zr.Close()
+			respBody = zr
+		case resp.StatusCode == http.StatusOK:
+			return nil, fmt.Errorf("decoding gzip response: %w", err)
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		// Railway usually explains 4xx responses in a JSON body; keep a bounded
+		// prefix of it for the error message.
+		snippet, _ := io.ReadAll(io.LimitReader(respBody, maxErrorBody))
+		slog.Debug(fmt.Sprintf("GraphQL response %d: %s", resp.StatusCode, snippet), Icon("🐛"),
+			"status", resp.StatusCode, "body", string(snippet))
+		se := &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(snippet))}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			se.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return nil, se
+	}
+
+	raw, err := io.ReadAll(respBody)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("GraphQL response %d broke off after %d bytes: %q", resp.StatusCode, len(raw), raw), Icon("🐛"),
+			"status", resp.StatusCode, "body", string(raw), "error", err)
+		// A body that ends early is worth asking for again; a corrupt gzip
+		// stream is not.
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("reading response: %w: %w", errTruncatedResponse, err)
+		}
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	slog.Debug(fmt.Sprintf("GraphQL response %d: %s", resp.StatusCode, raw), Icon("🐛"),
+		"status", resp.StatusCode, "body", string(raw))
+
+	var gqlResp graphqlResponse
+	if err := json.Unmarshal(raw, &gqlResp); err != nil {
+		slog.Debug(fmt.Sprintf("GraphQL response %d could not be decoded (%d bytes): %q", resp.StatusCode, len(raw), raw), Icon("🐛"),
+			"status", resp.StatusCode, "body", string(raw), "error", err)
+		// Only JSON that stops short is worth asking for again; complete but
+		// malformed JSON, even with junk at its very end, will not improve.
+		if err.Error() == errUnexpectedEndOfJSON {
+			return nil, fmt.Errorf("decoding response: %w: %w", errTruncatedResponse, err)
+		}
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	if len(gqlResp.Errors) > 0 {
+		return nil, graphqlErrors(gqlResp.Errors)
+	}
+
+	return &gqlResp, nil
+}
+
+const queryLatestDeployment = `
+query ($projectId: String!, $environmentId: String!, $serviceId: String!, $statuses: [DeploymentStatus!]!, $first: Int!) {
+  deployments(
+    first: $first
+    input: {
+      projectId: $projectId
+      environmentId: $environmentId
+      serviceId: $serviceId
+      status: { in: $statuses }
+    }
+  ) {
+    edges {
+      node {
+        id
+        status
+        createdAt
+      }
+    }
+  }
+}`
+
+// queryDeploymentsBatch is filled in with the per-service variable
+// declarations and one deploymentsBatchField per service.
+const queryDeploymentsBatch = `
+query deploymentsBatch($projectId: String!, $environmentId: String!, $statuses: [DeploymentStatus!]!, $first: Int!%s) {%s
+}`
+
+const deploymentsBatchField = `
+  %[1]s: deployments(
+    first: $first
+    input: {
+      projectId: $projectId
+      environmentId: $environmentId
+      serviceId: $%[1]s
+      status: { in: $statuses }
+    }
+  ) {
+    edges {
+      node {
+        id
+        status
+        createdAt
+      }
+    }
+  }`
+
+const mutationRestart = `
+mutation ($id: String!) {
+  deploymentRestart(id: $id)
+}`
+
+const mutationRedeploy = `
+mutation ($id: String!) {
+  deploymentRedeploy(id: $id) {
+    id
+  }
+}`
+
+// DeploymentAction is an operation that can be triggered on a deployment.
+type DeploymentAction struct {
+	Name     string
+	Mutation string
+	// Field is the mutation's result field; a false or null result means the
+	// action was not applied.
+	Field   string
+	Present string
+	Past    string
+}
+
+// DeploymentActions are the operations selectable with -action.
+var DeploymentActions = map[string]DeploymentAction{
+	"restart":  {Name: "restart", Mutation: mutationRestart, Field: "deploymentRestart", Present: "Restarting", Past: "restarted"},
+	"redeploy": {Name: "redeploy", Mutation: mutationRedeploy, Field: "deploymentRedeploy", Present: "Redeploying", Past: "redeployed"},
+}
+
+// defaultStatuses are the deployment statuses eligible for restart unless
+// -status says otherwise.
+var defaultStatuses = []string{"SUCCESS"}
+
+// deploymentStatuses are the values of Railway's DeploymentStatus enum.
+var deploymentStatuses = []string{
+	"BUILDING", "CRASHED", "DEPLOYING", "FAILED", "INITIALIZING", "NEEDS_APPROVAL", "QUEUED",
+	"REMOVED", "REMOVING", "SKIPPED", "SLEEPING", "SUCCESS", "WAITING",
+}
+
+// parseStatuses parses a comma-separated list of deployment statuses,
+// rejecting values that are not part of the DeploymentStatus enum.
+func parseStatuses(raw string) ([]string, error) {
+	var statuses []string
+	for _, s := range trimIDs(strings.Split(raw, ",")) {
+		s = strings.ToUpper(s)
+		if !slices.Contains(deploymentStatuses, s) {
+			return nil, fmt.Errorf("unknown deployment status %q (valid: %s)", s, strings.Join(deploymentStatuses, ", "))
+		}
+		statuses = append(statuses, s)
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("at least one deployment status is required")
+	}
+	return statuses, nil
+}
+
+// Deployments fetches a page of a service's deployments whose status is one of
+// statuses, newest first. They are sorted by createdAt rather than relying on
+// the API's ordering.
+func (c *Client) Deployments(ctx context.Context, projectID, environmentID, serviceID string, statuses []string) ([]Deployment, error) {
+	resp, err := c.do(ctx, queryLatestDeployment, map[string]any{
+		"projectId":     projectID,
+		"environmentId": environmentID,
+		"serviceId":     serviceID,
+		"statuses":      statuses,
+		"first":         c.deploymentsLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying deployments: %w", err)
+	}
+
+	var data deploymentsData
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, fmt.Errorf("parsing deployments: %w", err)
+	}
+
+	return data.Deployments.nodes(), nil
+}
+
+// deploymentsBatchSize caps how many services DeploymentsBatch looks up in a
+// single request.
+const deploymentsBatchSize = 50
+
+// DeploymentsBatch fetches the deployments of several services in one
+// environment, as Deployments does for one, using a single aliased query per
+// deploymentsBatchSize services. It returns the number of requests made along
+// with the deployments keyed by service ID.
+func (c *Client) DeploymentsBatch(ctx context.Context, projectID, environmentID string, serviceIDs, statuses []string) (map[string][]Deployment, int, error) {
+	byService := make(map[string][]Deployment, len(serviceIDs))
+	requests := 0
+	for chunk := range slices.Chunk(serviceIDs, deploymentsBatchSize) {
+		variables := map[string]any{
+			"projectId":     projectID,
+			"environmentId": environmentID,
+			"statuses":      statuses,
+			"first":         c.deploymentsLimit,
+		}
+		var params, fields strings.Builder
+		for i, id := range chunk {
+			alias := fmt.Sprintf("s%d", i)
+			variables[alias] = id
+			fmt.Fprintf(&params, ", $%s: String!", alias)
+			fmt.Fprintf(&fields, deploymentsBatchField, alias)
+		}
+		query := fmt.Sprintf(queryDeploymentsBatch, params.String(), fields.String())
+
+		requests++
+		resp, err := c.do(ctx, query, variables)
+		if err != nil {
+			return nil, requests, fmt.Errorf("querying deployments: %w", err)
+		}
+		var data map[string]deploymentConnection
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			return nil, requests, fmt.Errorf("parsing deployments: %w", err)
+		}
+		for i, id := range chunk {
+			conn, ok := data[fmt.Sprintf("s%d", i)]
+			if !ok {
+				return nil, requests, fmt.Errorf("parsing deployments: no result for service %s", id)
+			}
+			byService[id] = conn.nodes()
+		}
+	}
+	return byService, requests, nil
+}
+
+// LatestDeployment fetches the latest deployment for a service whose status is
+// one of statuses.
+func (c *Client) LatestDeployment(ctx context.Context, projectID, environmentID, serviceID string, statuses []string) (Deployment, error) {
+	deployments, err := c.Deployments(ctx, projectID, environmentID, serviceID, statuses)
+	if err != nil {
+		return Deployment{}, err
+	}
+	return latestOf(deployments, statuses)
+}
+
+// PreviousDeployment fetches the second most recent deployment for a service
+// whose status is one of statuses, i.e. the one to roll back to. It also
+// returns the current (latest) deployment.
+func (c *Client) PreviousDeployment(ctx context.Context, projectID, environmentID, serviceID string, statuses []string) (previous, current Deployment, err error) {
+	deployments, err := c.Deployments(ctx, projectID, environmentID, serviceID, statuses)
+	if err != nil {
+		return Deployment{}, Deployment{}, err
+	}
+	return previousOf(deployments, statuses)
+}
+
+// latestOf picks the latest of deployments, sorted newest first, that were
+// fetched for statuses.
+func latestOf(deployments []Deployment, statuses []string) (Deployment, error) {
+	if len(deployments) == 0 {
+		return Deployment{}, fmt.Errorf("%w (status %s)", ErrNoDeployment, strings.Join(statuses, "/"))
+	}
+	return deployments[0], nil
+}
+
+// previousOf picks the deployment before the latest of deployments, sorted
+// newest first, that were fetched for statuses.
+func previousOf(deployments []Deployment, statuses []string) (previous, current Deployment, err error) {
+	switch len(deployments) {
+	case 0:
+		return Deployment{}, Deployment{}, fmt.Errorf("%w (status %s)", ErrNoDeployment, strings.Join(statuses, "/"))
+	case 1:
+		return Deployment{}, deployments[0], fmt.Errorf("%w (only %s has status %s)", ErrNoPreviousDeployment, deployments[0].ID, strings.Join(statuses, "/"))
+	}
+	return deployments[1], deployments[0], nil
+}
+
+// CurrentDeployment fetches the newest deployment of a service in any status.
+func (c *Client) CurrentDeployment(ctx context.Context, projectID, environmentID, serviceID string) (Deployment, error) {
+	deployments, err := c.Deployments(ctx, projectID, environmentID, serviceID, deploymentStatuses)
+	if err != nil {
+		return Deployment{}, err
+	}
+	if len(deployments) == 0 {
+		return Deployment{}, ErrNoDeployment
+	}
+	return deployments[0], nil
+}
+
+// InProgressDeployment reports whether the newest deployment of a service, in
+// any status, is still in progress, returning it if so.
+func (c *Client) InProgressDeployment(ctx context.Context, projectID, environmentID, serviceID string) (Deployment, bool, error) {
+	deployments, err := c.Deployments(ctx, projectID, environmentID, serviceID, deploymentStatuses)
+	if err != nil || len(deployments) == 0 {
+		return Deployment{}, false, err
+	}
+	return deployments[0], slices.Contains(inProgressStatuses, deployments[0].Status), nil
+}
+
+// Restart restarts the given deployment.
+func (c *Client) Restart(ctx context.Context, deploymentID string) error {
+	return c.Trigger(ctx, DeploymentActions["restart"], deploymentID)
+}
+
+// Redeploy redeploys the given deployment.
+func (c *Client) Redeploy(ctx context.Context, deploymentID string) error {
+	return c.Trigger(ctx, DeploymentActions["redeploy"], deploymentID)
+}
+
+// Trigger runs action's mutation for the given deployment ID.
+func (c *Client) Trigger(ctx context.Context, action DeploymentAction, deploymentID string) error {
+	resp, err := c.mutate(ctx, action.Mutation, map[string]any{
+		"id": deploymentID,
+	})
+	if err != nil {
+		return fmt.Errorf("%s deployment: %w", strings.ToLower(action.Present), err)
+	}
+
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return fmt.Errorf("parsing %s response: %w", action.Name, err)
+	}
+	switch result := strings.TrimSpace(string(data[action.Field])); result {
+	case "", "null", "false":
+		return fmt.Errorf("%s deployment: %s returned %s", strings.ToLower(action.Present), action.Field, cmp.Or(result, "nothing"))
+	}
+	return nil
+}
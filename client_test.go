@@ -0,0 +1,229 @@
+package railflush
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// gzipped compresses s.
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// sendTo sends a request with c.send to a server answering with status,
+// Content-Encoding encoding and body.
+func sendTo(t *testing.T, status int, encoding string, body []byte) (*graphqlResponse, error) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept-Encoding"); got != "gzip" {
+			t.Errorf("Accept-Encoding = %q, want gzip", got)
+		}
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	defer srv.Close()
+	c := NewClient(srv.Client(), srv.URL, "token", RetryPolicy{})
+	return c.send(context.Background(), []byte(`{"query":"query { me { id } }"}`), "req-1", 1)
+}
+
+func TestSendGzip(t *testing.T) {
+	resp, err := sendTo(t, http.StatusOK, "gzip", gzipped(t, `{"data":{"me":{"id":"u1"}}}`))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got, want := string(resp.Data), `{"me":{"id":"u1"}}`; got != want {
+		t.Errorf("data = %s, want %s", got, want)
+	}
+}
+
+func TestSendGzipErrorStatus(t *testing.T) {
+	_, err := sendTo(t, http.StatusUnauthorized, "gzip", gzipped(t, `{"error":"Unauthorized"}`))
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("err = %v, want a *StatusError", err)
+	}
+	if se.StatusCode != http.StatusUnauthorized || se.Body != `{"error":"Unauthorized"}` {
+		t.Errorf("StatusError = %d %q, want 401 with the decoded body", se.StatusCode, se.Body)
+	}
+}
+
+func TestSendCorruptGzip(t *testing.T) {
+	valid := gzipped(t, `{"data":{"me":{"id":"u1"}}}`)
+	corrupt := bytes.Clone(valid)
+	corrupt[len(corrupt)-10] ^= 0xff // in the compressed data or its checksum
+
+	tests := []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{"not gzip", []byte(`{"data":{}}`), "decoding gzip response"},
+		{"corrupt stream", corrupt, "reading response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sendTo(t, http.StatusOK, "gzip", tt.body)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.want)
+			}
+			if errors.Is(err, errTruncatedResponse) {
+				t.Errorf("err = %v, want a corrupt body not to be retried as truncated", err)
+			}
+		})
+	}
+}
+
+func TestSendTruncated(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength string
+		truncated     bool
+	}{
+		{"JSON cut short", `{"data":{"me":`, "", true},
+		{"body cut short", `{"data":{"me":`, "100", true},
+		{"trailing junk", `{"data": 1}}`, "", false},
+		{"malformed", `{"data": nope}`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentLength != "" {
+					w.Header().Set("Content-Length", tt.contentLength)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			c := NewClient(srv.Client(), srv.URL, "token", RetryPolicy{})
+			_, err := c.send(context.Background(), []byte(`{"query":"query { me { id } }"}`), "req-1", 1)
+			if err == nil {
+				t.Fatal("send succeeded, want an error")
+			}
+			if got := errors.Is(err, errTruncatedResponse); got != tt.truncated {
+				t.Errorf("err = %v, truncated = %t, want %t", err, got, tt.truncated)
+			}
+			if got := isRetryable(err); got != tt.truncated {
+				t.Errorf("isRetryable(%v) = %t, want %t", err, got, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestDeploymentsBatch(t *testing.T) {
+	var ids []string
+	for i := range 2*deploymentsBatchSize + 3 {
+		ids = append(ids, fmt.Sprintf("svc-%d", i))
+	}
+	// Every third service has no deployments.
+	deployments := map[string][]Deployment{}
+	for i, id := range ids {
+		if i%3 != 0 {
+			deployments[id] = []Deployment{{ID: "dep-" + id, Status: "SUCCESS"}, {ID: "old-" + id, Status: "SUCCESS"}}
+		}
+	}
+	api := newFakeAPI(t, deployments)
+
+	got, requests, err := api.client().DeploymentsBatch(context.Background(), "p", "e", ids, defaultStatuses)
+	if err != nil {
+		t.Fatalf("DeploymentsBatch: %v", err)
+	}
+	if requests != 3 || api.count("deploymentsBatch") != 3 {
+		t.Errorf("made %d requests (fake saw %d), want 3", requests, api.count("deploymentsBatch"))
+	}
+	if len(got) != len(ids) {
+		t.Errorf("got results for %d services, want %d", len(got), len(ids))
+	}
+	for i, id := range ids {
+		deps, ok := got[id]
+		switch {
+		case !ok:
+			t.Errorf("%s: missing from the result", id)
+		case i%3 == 0 && len(deps) != 0:
+			t.Errorf("%s: got %v, want no deployments", id, deps)
+		case i%3 != 0 && (len(deps) != 2 || deps[0].ID != "dep-"+id):
+			t.Errorf("%s: got %v, want dep-%s first of 2", id, deps, id)
+		}
+	}
+}
+
+func TestDeploymentsBatchMissingAlias(t *testing.T) {
+	api := newFakeAPI(t, nil)
+	api.override = func(fakeCall) (int, string) {
+		return http.StatusOK, `{"data":{"s0":{"edges":[]}}}`
+	}
+	_, _, err := api.client().DeploymentsBatch(context.Background(), "p", "e", []string{"a", "b"}, defaultStatuses)
+	if want := "no result for service b"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("DeploymentsBatch error = %v, want one containing %q", err, want)
+	}
+}
+
+func TestExplainAccessError(t *testing.T) {
+	gql := func(msg string, code any) error {
+		e := graphqlError{Message: msg}
+		if code != nil {
+			e.Extensions = map[string]any{"code": code}
+		}
+		return fmt.Errorf("querying deployments: %w", graphqlErrors{e})
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool // whether err is explained as an access error
+	}{
+		{"Not Authorized", gql("Not Authorized", nil), true},
+		{"message case and punctuation", gql("not authorized.", nil), true},
+		{"forbidden code", gql("Something went wrong", "FORBIDDEN"), true},
+		{"unauthenticated code", gql("Problem processing request", "UNAUTHENTICATED"), true},
+		{"one of several errors", fmt.Errorf("x: %w", graphqlErrors{{Message: "Problem processing request"}, {Message: "Not Authorized"}}), true},
+		{"403", &StatusError{StatusCode: http.StatusForbidden}, true},
+		{"mentions permission", gql(`Cannot query field "permissions" on type "Project"`, nil), false},
+		{"mentions unauthorized", gql("Failed to restart: upstream returned unauthorized for image pull", nil), false},
+		{"other code", gql("Not Authorized?", "INTERNAL_SERVER_ERROR"), false},
+		{"plain error", errors.New("permission denied"), false},
+		{"500", &StatusError{StatusCode: http.StatusInternalServerError, Body: "forbidden"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := explainAccessError(tt.err, map[string]any{"projectId": "p1"}, TokenTypeAccount)
+			if got := errors.Is(err, ErrAuth); got != tt.want {
+				t.Errorf("explainAccessError(%v) matches ErrAuth = %v, want %v", tt.err, got, tt.want)
+			}
+			if tt.want && !strings.Contains(err.Error(), "token lacks access to project p1") {
+				t.Errorf("explainAccessError = %v, want guidance naming project p1", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("explainAccessError = %v, want it to wrap %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAccessErrorFromAPI(t *testing.T) {
+	api := newFakeAPI(t, nil)
+	api.override = func(fakeCall) (int, string) {
+		return http.StatusOK, `{"data":null,"errors":[{"message":"Not Authorized","extensions":{"code":"FORBIDDEN"}}]}`
+	}
+	_, err := api.client().Deployments(context.Background(), "p1", "e", "a", defaultStatuses)
+	if !errors.Is(err, ErrAuth) || !strings.Contains(err.Error(), "token lacks access to project p1") {
+		t.Errorf("Deployments error = %v, want an access error naming project p1", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/berry/railflush"
+)
+
+// errNotConfirmed is returned when the confirmation prompt is declined.
+var errNotConfirmed = errors.New("restart not confirmed")
+
+// confirmOnce returns a railflush.Config.Confirm hook that lists the planned
+// services on out and asks for a "y" on in. Once confirmed, later runs of an
+// -interval loop are not asked again.
+func confirmOnce(in io.Reader, out io.Writer) func(context.Context, []railflush.PlannedService) error {
+	answers := bufio.NewReader(in)
+	confirmed := false
+	return func(ctx context.Context, services []railflush.PlannedService) error {
+		if confirmed {
+			return nil
+		}
+		writePlan(out, services)
+		fmt.Fprintf(out, "Restart these %d service(s)? [y/N] ", len(services))
+
+		// Reading is not cancelable, so an interrupt abandons it instead.
+		answer := make(chan string, 1)
+		go func() {
+			line, _ := answers.ReadString('\n')
+			answer <- line
+		}()
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(out)
+			return ctx.Err()
+		case line := <-answer:
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "y", "yes":
+				confirmed = true
+				return nil
+			}
+			return errNotConfirmed
+		}
+	}
+}
+
+// writePlan lists services grouped by project and environment, in order.
+func writePlan(w io.Writer, services []railflush.PlannedService) {
+	for i, s := range services {
+		if i == 0 || s.ProjectID != services[i-1].ProjectID || s.EnvironmentID != services[i-1].EnvironmentID {
+			fmt.Fprintf(w, "Project %s, environment %s:\n", s.ProjectID, s.EnvironmentID)
+		}
+		fmt.Fprintf(w, "  - %s\n", s.ServiceID)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/berry/railflush"
+)
+
+// selectFields encodes v, a struct, as a JSON object with only the given
+// fields, in that order, for -json-fields. Fields v leaves out, such as empty
+// omitempty ones, are left out here too.
+func selectFields(v any, fields []string) (json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, f := range fields {
+		value, ok := all[f]
+		if !ok {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// withFields returns summary ready to encode for -output json, its services
+// cut down to fields when any are given.
+func withFields(summary railflush.Summary, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return summary, nil
+	}
+	services := make([]json.RawMessage, len(summary.Services))
+	for i, r := range summary.Services {
+		var err error
+		if services[i], err = selectFields(r, fields); err != nil {
+			return nil, err
+		}
+	}
+	return struct {
+		railflush.Summary
+		// Services shadows the summary's own list.
+		Services []json.RawMessage `json:"services"`
+	}{summary, services}, nil
+}
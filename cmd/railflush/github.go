@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/berry/railflush"
+)
+
+// inGitHubActions reports whether railflush runs as a GitHub Actions step.
+func inGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// writeGitHubOutputs appends the run's step outputs to the $GITHUB_OUTPUT file,
+// if the runner provides one.
+func writeGitHubOutputs(summary railflush.Summary, cfg railflush.Config) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return nil
+	}
+
+	var restarted []string
+	for _, r := range summary.Services {
+		if r.Status == cfg.Action.Past {
+			restarted = append(restarted, r.ServiceID)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening GITHUB_OUTPUT: %w", err)
+	}
+	_, err = fmt.Fprintf(f, "succeeded=%d\nfailed=%d\nrestarted_services=%s\n", summary.Succeeded, summary.Failed, strings.Join(restarted, ","))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("writing GITHUB_OUTPUT: %w", err)
+	}
+	return nil
+}
+
+// writeGitHubAnnotations emits an ::error:: workflow command for every failed
+// service so failures show up in the Actions UI.
+func writeGitHubAnnotations(w io.Writer, summary railflush.Summary) {
+	for _, r := range summary.Services {
+		if r.Status != railflush.StatusFailed {
+			continue
+		}
+		title := "railflush: service " + r.ServiceID
+		fmt.Fprintf(w, "::error title=%s::%s\n", escapeWorkflowProperty(title), escapeWorkflowData(
+			fmt.Sprintf("Service %s in environment %s: %s", r.ServiceID, r.EnvironmentID, r.Error)))
+	}
+}
+
+// escapeWorkflowData escapes a workflow command message.
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeWorkflowProperty escapes a workflow command property value.
+func escapeWorkflowProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
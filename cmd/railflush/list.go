@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/berry/railflush"
+)
+
+// runList lists the services of the configured environments for -list and
+// returns the exit code.
+func runList(cfg railflush.Config) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	services, err := railflush.List(ctx, cfg)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Listing services failed: %v", err), railflush.Icon("❌"), "error", err)
+		switch {
+		case errors.Is(err, railflush.ErrAuth):
+			return exitAuth
+		case ctx.Err() != nil:
+			return exitInterrupted
+		}
+		return exitFailed
+	}
+
+	switch cfg.Output {
+	case railflush.OutputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		if cfg.JSONPretty {
+			enc.SetIndent("", "  ")
+		}
+		err = enc.Encode(services)
+	case railflush.OutputNDJSON:
+		enc := json.NewEncoder(os.Stdout)
+		for _, s := range services {
+			if err = enc.Encode(s); err != nil {
+				break
+			}
+		}
+	default:
+		err = writeServiceList(os.Stdout, services)
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("Writing service list: %v", err), railflush.Icon("❌"), "error", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// writeServiceList writes services as an aligned table. As with -output table,
+// project and environment columns are only shown when there is more than one.
+func writeServiceList(w io.Writer, services []railflush.ListedService) error {
+	var projects, environments []string
+	for _, s := range services {
+		if !slices.Contains(projects, s.ProjectID) {
+			projects = append(projects, s.ProjectID)
+		}
+		if !slices.Contains(environments, s.EnvironmentID) {
+			environments = append(environments, s.EnvironmentID)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(name, id, project, environment, deployment, status, created string) {
+		fmt.Fprintf(tw, "%s\t%s", name, id)
+		if len(projects) > 1 {
+			fmt.Fprint(tw, "\t"+project)
+		}
+		if len(environments) > 1 {
+			fmt.Fprint(tw, "\t"+environment)
+		}
+		fmt.Fprintf(tw, "\t%s\t%s\t%s\n", deployment, status, created)
+	}
+	row("NAME", "SERVICE", "PROJECT", "ENVIRONMENT", "DEPLOYMENT", "STATUS", "CREATED")
+	for _, s := range services {
+		deployment, status, created := "—", "—", "—"
+		if s.DeploymentID != "" {
+			deployment, status = s.DeploymentID, s.Status
+		}
+		if s.CreatedAt != nil {
+			created = s.CreatedAt.Format(time.RFC3339)
+		}
+		row(s.ServiceName, s.ServiceID, s.ProjectID, s.EnvironmentID, deployment, status, created)
+	}
+	return tw.Flush()
+}
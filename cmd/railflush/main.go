@@ -0,0 +1,280 @@
+// Command railflush restarts the latest deployments of Railway services.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/berry/railflush"
+)
+
+// Exit codes, so CI and wrappers can tell failures apart.
+const (
+	exitOK = 0
+	// exitFailed: a service failed or was skipped, the run could not start,
+	// or its output could not be written.
+	exitFailed = 1
+	// exitConfig: the configuration is invalid or incomplete.
+	exitConfig = 2
+	// exitAuth: the API token was rejected or lacks access.
+	exitAuth = 3
+	// exitInterrupted: the run was stopped by SIGINT or SIGTERM.
+	exitInterrupted = 4
+)
+
+func main() {
+	summaryOnly := summaryOnlyRequested(os.Args[1:])
+	if summaryOnly {
+		// Nothing may reach stderr, not even flag errors printed before the
+		// configuration, and so -summary-only, is known.
+		if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			os.Stderr = devNull
+		}
+	}
+
+	cfg, err := railflush.LoadConfig(os.Args[1:])
+	if err != nil {
+		if summaryOnly {
+			fmt.Printf("Configuration error: %v\n", err)
+			os.Exit(exitConfig)
+		}
+		// The logger depends on the configuration, so report this directly.
+		prefix := "❌"
+		if os.Getenv("NO_COLOR") != "" {
+			prefix = "[ERROR]"
+		}
+		fmt.Fprintf(os.Stderr, "%s Configuration error: %v\n", prefix, err)
+		os.Exit(exitConfig)
+	}
+
+	if cfg.ShowVersion {
+		fmt.Println(railflush.VersionString())
+		return
+	}
+
+	if cfg.PrintConfig {
+		if err := printConfig(os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Printing configuration: %v\n", err)
+			os.Exit(exitFailed)
+		}
+		return
+	}
+
+	if cfg.Progress == railflush.ProgressAuto {
+		cfg.Progress = autoProgress(cfg)
+	}
+	slog.SetDefault(railflush.NewLogger(cfg))
+
+	if cfg.List {
+		os.Exit(runList(cfg))
+	}
+
+	slog.Info("railflush — restarting Railway deployments", railflush.Icon("🚂"))
+	for _, w := range cfg.Warnings {
+		slog.Warn(w, railflush.Icon("⚠️"))
+	}
+
+	if !cfg.DryRun && !cfg.Yes {
+		if !isTerminal(os.Stdin) {
+			msg := "Not restarting without confirmation: stdin is not a terminal, so pass -yes (or -dry-run to preview)"
+			slog.Error(msg, railflush.Icon("❌"))
+			if cfg.SummaryOnly {
+				fmt.Println(msg)
+			}
+			os.Exit(exitConfig)
+		}
+		cfg.Confirm = confirmOnce(os.Stdin, os.Stderr)
+	}
+
+	// A signal only stops new services from being dispatched; services already
+	// in flight keep running so their requests can finish. Calling stop
+	// restores the default handlers, so a second signal exits immediately.
+	var running atomic.Bool
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		if running.Load() {
+			slog.Warn("Interrupted, waiting for in-flight services to finish (signal again to force quit)", railflush.Icon("🛑"))
+		}
+	}()
+
+	if cfg.Interval == 0 {
+		running.Store(true)
+		os.Exit(runOnce(ctx, cfg))
+	}
+	watch(ctx, cfg, &running)
+}
+
+// autoProgress resolves -progress auto: nothing with -quiet or -summary-only,
+// a bar when stderr is a terminal and, otherwise, a progress line every 10
+// seconds.
+func autoProgress(cfg railflush.Config) string {
+	switch {
+	case cfg.Quiet, cfg.SummaryOnly:
+		return railflush.ProgressNone
+	case isTerminal(os.Stderr):
+		return railflush.ProgressBar
+	}
+	return railflush.ProgressLines
+}
+
+// watch runs railflush every cfg.Interval until ctx is canceled. Runs never
+// overlap: one that takes longer than the interval delays the next.
+func watch(ctx context.Context, cfg railflush.Config, running *atomic.Bool) {
+	for n := 1; ; n++ {
+		start := time.Now()
+		slog.Info(fmt.Sprintf("───── Run #%d ─────", n), railflush.Icon("🕒"), "run", n)
+		running.Store(true)
+		code := runOnce(ctx, cfg)
+		running.Store(false)
+		if ctx.Err() != nil {
+			os.Exit(code)
+		}
+
+		wait := time.Until(start.Add(cfg.Interval))
+		if wait <= 0 {
+			slog.Warn(fmt.Sprintf("Run took %s, longer than -interval %s; starting the next run now", time.Since(start).Round(time.Millisecond), cfg.Interval),
+				railflush.Icon("⚠️"), "interval", cfg.Interval.String())
+			continue
+		}
+		next := start.Add(cfg.Interval)
+		slog.Info(fmt.Sprintf("Next run at %s", next.Format(time.RFC3339)), railflush.Icon("💤"), "next_run", next)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("Interrupted, stopping", railflush.Icon("🛑"))
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// runOnce performs a single run and writes its outputs. It returns the exit
+// code the run warrants.
+func runOnce(ctx context.Context, cfg railflush.Config) int {
+	start := time.Now()
+
+	var stream *ndjsonWriter
+	if cfg.Output == railflush.OutputNDJSON {
+		stream = &ndjsonWriter{w: os.Stdout, fields: cfg.JSONFields}
+		cfg.OnResult = stream.result
+	}
+
+	summary, err := railflush.Run(ctx, cfg)
+	if cfg.SummaryOnly {
+		if err != nil {
+			fmt.Printf("Run aborted: %v\n", err)
+		} else if summary.Aborted != "" {
+			fmt.Printf("%s, aborted: %s\n", railflush.SummaryLine(summary, cfg), summary.Aborted)
+		} else {
+			fmt.Println(railflush.SummaryLine(summary, cfg))
+		}
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("Run aborted: %v", err), railflush.Icon("❌"), "error", err)
+		switch {
+		case errors.Is(err, railflush.ErrAuth):
+			return exitAuth
+		case errors.Is(err, railflush.ErrConfig):
+			return exitConfig
+		case ctx.Err() != nil:
+			return exitInterrupted
+		}
+		return exitFailed
+	}
+
+	if cfg.Output == railflush.OutputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		if cfg.JSONPretty {
+			enc.SetIndent("", "  ")
+		}
+		out, err := withFields(summary, cfg.JSONFields)
+		if err == nil {
+			err = enc.Encode(out)
+		}
+		if err != nil {
+			slog.Error(fmt.Sprintf("Writing JSON output: %v", err), railflush.Icon("❌"), "error", err)
+			return exitFailed
+		}
+	}
+
+	if stream != nil {
+		if err := stream.summary(summary); err != nil {
+			slog.Error(fmt.Sprintf("Writing NDJSON output: %v", err), railflush.Icon("❌"), "error", err)
+			return exitFailed
+		}
+	}
+
+	if cfg.Output == railflush.OutputTable {
+		if err := writeTable(os.Stdout, summary); err != nil {
+			slog.Error(fmt.Sprintf("Writing table output: %v", err), railflush.Icon("❌"), "error", err)
+			return exitFailed
+		}
+	}
+
+	reportFailed := false
+	if cfg.ReportPath != "" {
+		if err := writeReportFile(summary, cfg, start); err != nil {
+			slog.Error(fmt.Sprintf("Report not saved: %v", err), railflush.Icon("❌"), "error", err)
+			reportFailed = true
+		}
+	}
+
+	if inGitHubActions() && !cfg.SummaryOnly {
+		// Workflow commands are read from stdout, unless it carries -output json
+		// or ndjson.
+		annotations := os.Stdout
+		if cfg.Output == railflush.OutputJSON || cfg.Output == railflush.OutputNDJSON {
+			annotations = os.Stderr
+		}
+		writeGitHubAnnotations(annotations, summary)
+		if err := writeGitHubOutputs(summary, cfg); err != nil {
+			slog.Warn(fmt.Sprintf("GitHub Actions outputs not written: %v", err), railflush.Icon("⚠️"), "error", err)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return exitInterrupted
+	}
+	if slices.ContainsFunc(summary.Services, func(r railflush.ServiceResult) bool { return errors.Is(r.Err(), railflush.ErrAuth) }) {
+		return exitAuth
+	}
+	if summary.Failed > 0 || summary.Skipped > 0 || reportFailed {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// summaryOnlyRequested reports whether args set -summary-only, which has to be
+// known before LoadConfig parses them.
+func summaryOnlyRequested(args []string) bool {
+	on := false
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "summary-only" {
+			continue
+		}
+		on = true
+		if hasValue {
+			on, _ = strconv.ParseBool(value)
+		}
+	}
+	return on
+}
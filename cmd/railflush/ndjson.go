@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/berry/railflush"
+)
+
+// ndjsonWriter streams -output ndjson: a "service" line per result as it
+// completes, then a "summary" line. Each line is written with a single
+// Write, and railflush.Config.OnResult is never called concurrently, so lines
+// are not torn.
+type ndjsonWriter struct {
+	w io.Writer
+	// fields are the -json-fields that service lines are cut down to.
+	fields []string
+	// err is the first write error, reported once the run is over.
+	err error
+}
+
+// result writes r as a "service" line.
+func (n *ndjsonWriter) result(r railflush.ServiceResult) {
+	line := struct {
+		Type string `json:"type"`
+		railflush.ServiceResult
+	}{"service", r}
+	if len(n.fields) == 0 {
+		n.write(line)
+		return
+	}
+	trimmed, err := selectFields(line, append([]string{"type"}, n.fields...))
+	if err != nil {
+		if n.err == nil {
+			n.err = err
+		}
+		return
+	}
+	n.write(trimmed)
+}
+
+// summary writes s, without the services already streamed, as a "summary"
+// line and returns the first error of the run's writes.
+func (n *ndjsonWriter) summary(s railflush.Summary) error {
+	n.write(struct {
+		Type string `json:"type"`
+		railflush.Summary
+		// Services shadows the summary's own list to leave it out.
+		Services []railflush.ServiceResult `json:"services,omitempty"`
+	}{Type: "summary", Summary: s})
+	return n.err
+}
+
+func (n *ndjsonWriter) write(v any) {
+	if n.err != nil {
+		return
+	}
+	b, err := json.Marshal(v)
+	if err == nil {
+		_, err = n.w.Write(append(b, '\n'))
+	}
+	n.err = err
+}
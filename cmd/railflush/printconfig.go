@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/berry/railflush"
+)
+
+// printConfig writes the effective configuration to w, one setting per line.
+// Secrets are masked.
+func printConfig(w io.Writer, cfg railflush.Config) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(name string, value any) {
+		if value == "" {
+			value = "—"
+		}
+		fmt.Fprintf(tw, "%s\t%v\n", name, value)
+	}
+	list := func(values []string) string {
+		return strings.Join(values, ",")
+	}
+
+	row("api_token", maskToken(cfg.APIToken))
+	row("token_type", cfg.TokenType)
+	row("api_url", cfg.APIURL)
+	proxy := "from environment"
+	if cfg.Proxy != nil {
+		proxy = maskURL(cfg.Proxy.String())
+	}
+	row("proxy", proxy)
+	caCerts := "system"
+	if cfg.RootCAs != nil {
+		caCerts = "system and -ca-cert"
+	}
+	row("ca_certs", caCerts)
+	row("insecure_skip_verify", cfg.InsecureSkipVerify)
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = railflush.DefaultUserAgent()
+	}
+	row("user_agent", userAgent)
+	row("project_id", cfg.ProjectID)
+	row("environment_ids", list(cfg.EnvironmentIDs))
+	row("service_ids", list(cfg.ServiceIDs))
+	row("service_names", list(cfg.ServiceNames))
+	var mapped []string
+	for _, envID := range slices.Sorted(maps.Keys(cfg.EnvironmentServiceIDs)) {
+		mapped = append(mapped, envID+"="+strings.Join(cfg.EnvironmentServiceIDs[envID], "|"))
+	}
+	row("environment_service_ids", strings.Join(mapped, " "))
+	row("all", cfg.All)
+	row("list", cfg.List)
+	row("yes", cfg.Yes)
+	row("no_service_cache", cfg.NoServiceCache)
+	row("no_batch", cfg.NoBatch)
+	var overrides []string
+	for _, id := range slices.Sorted(maps.Keys(cfg.DeploymentIDs)) {
+		overrides = append(overrides, id+"="+cfg.DeploymentIDs[id])
+	}
+	row("deployment_ids", list(overrides))
+	for i, g := range cfg.Projects {
+		row(fmt.Sprintf("projects[%d]", i), fmt.Sprintf("project_id=%s environment_ids=%s service_ids=%s service_names=%s",
+			g.ProjectID, list(g.EnvironmentIDs), list(g.ServiceIDs), list(g.ServiceNames)))
+	}
+	row("only", list(cfg.Only))
+	row("skip", list(cfg.Skip))
+	row("newest_only", cfg.NewestOnly)
+	row("action", cfg.Action.Name)
+	row("statuses", list(cfg.Statuses))
+	row("restart_if", list(cfg.RestartIf))
+	row("dry_run", cfg.DryRun)
+	row("fail_fast", cfg.FailFast)
+	row("max_failures", cfg.MaxFailures)
+	row("preflight", cfg.Preflight)
+	row("check_permissions", cfg.CheckPermissions)
+	row("concurrency", cfg.Concurrency)
+	row("delay", cfg.Delay)
+	row("spread", cfg.Spread)
+	row("interval", cfg.Interval)
+	row("timeout", cfg.Timeout)
+	var timeouts []string
+	for _, id := range slices.Sorted(maps.Keys(cfg.ServiceTimeouts)) {
+		timeouts = append(timeouts, fmt.Sprintf("%s=%s", id, cfg.ServiceTimeouts[id]))
+	}
+	row("service_timeouts", list(timeouts))
+	row("max_idle_conns_per_host", cfg.MaxIdleConnsPerHost)
+	row("idle_conn_timeout", cfg.IdleConnTimeout)
+	row("http2", cfg.HTTP2)
+	row("deadline", cfg.Deadline)
+	row("max_retries", cfg.Retry.MaxRetries)
+	row("action_retries", cfg.Retry.ActionRetries)
+	row("backoff_base", cfg.Retry.BaseDelay)
+	row("backoff_max", cfg.Retry.MaxDelay)
+	row("backoff_factor", cfg.Retry.Factor)
+	row("backoff_jitter", !cfg.Retry.NoJitter)
+	row("retry_budget", cfg.RetryBudget)
+	row("retry_empty", cfg.RetryEmpty)
+	row("deployments_limit", cfg.DeploymentsLimit)
+	row("max_age", cfg.MaxAge)
+	timeRow := func(name string, t time.Time) {
+		if t.IsZero() {
+			row(name, "")
+			return
+		}
+		row(name, t.Format(time.RFC3339))
+	}
+	timeRow("since", cfg.Since)
+	timeRow("until", cfg.Until)
+	row("detect_in_progress", cfg.DetectInProgress)
+	row("require_healthy_before_restart", cfg.RequireHealthy)
+	row("pre_restart_cmd", cfg.PreRestartCmd)
+	row("post_restart_cmd", cfg.PostRestartCmd)
+	row("wait", cfg.Wait)
+	row("wait_timeout", cfg.WaitTimeout)
+	row("healthy_statuses", list(cfg.HealthyStatuses))
+	row("logs_on_failure", cfg.LogsOnFailure)
+	row("output", cfg.Output)
+	row("json_pretty", cfg.JSONPretty)
+	row("json_fields", list(cfg.JSONFields))
+	row("log_format", cfg.LogFormat)
+	row("log_to", cfg.LogTo)
+	row("progress", cfg.Progress)
+	row("summary_only", cfg.SummaryOnly)
+	row("report", cfg.ReportPath)
+	row("report_format", cfg.ReportFormat)
+	masked := func(urls []string) string {
+		out := make([]string, len(urls))
+		for i, u := range urls {
+			out[i] = maskURL(u)
+		}
+		return list(out)
+	}
+	row("slack_webhooks", masked(cfg.SlackWebhooks))
+	row("discord_webhooks", masked(cfg.DiscordWebhooks))
+	row("webhook_urls", masked(cfg.WebhookURLs))
+	row("pushgateway_url", cfg.PushgatewayURL)
+	return tw.Flush()
+}
+
+// maskToken hides all but the last four characters of token.
+func maskToken(token string) string {
+	if len(token) <= 4 {
+		return strings.Repeat("*", len(token))
+	}
+	return strings.Repeat("*", len(token)-4) + token[len(token)-4:]
+}
+
+// maskURL keeps only the scheme and host of a URL that may embed a secret in
+// its path or credentials.
+func maskURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "****"
+	}
+	return u.Scheme + "://" + u.Host + "/****"
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/berry/railflush"
+)
+
+// reportConfig is the subset of Config recorded in report files. Secrets such
+// as the API token and webhook URLs are deliberately left out.
+type reportConfig struct {
+	APIURL                string                   `json:"api_url"`
+	ProjectID             string                   `json:"project_id"`
+	EnvironmentIDs        []string                 `json:"environment_ids"`
+	ServiceIDs            []string                 `json:"service_ids,omitempty"`
+	ServiceNames          []string                 `json:"service_names,omitempty"`
+	EnvironmentServiceIDs map[string][]string      `json:"environment_service_ids,omitempty"`
+	Projects              []railflush.ProjectGroup `json:"projects,omitempty"`
+	Statuses              []string                 `json:"statuses"`
+	DryRun                bool                     `json:"dry_run"`
+	Wait                  bool                     `json:"wait"`
+	Concurrency           int                      `json:"concurrency"`
+	MaxRetries            int                      `json:"max_retries"`
+	Timeout               string                   `json:"timeout"`
+	Deadline              string                   `json:"deadline"`
+}
+
+// runRecord is one entry in a report file.
+type runRecord struct {
+	Timestamp time.Time    `json:"timestamp"`
+	Config    reportConfig `json:"config"`
+	railflush.Summary
+}
+
+// writeReportFile records the run in cfg.ReportPath. In NDJSON mode the record
+// is appended as a single line; otherwise the file is replaced.
+func writeReportFile(summary railflush.Summary, cfg railflush.Config, start time.Time) error {
+	record := runRecord{
+		Timestamp: start.UTC(),
+		Config: reportConfig{
+			APIURL:                cfg.APIURL,
+			ProjectID:             cfg.ProjectID,
+			EnvironmentIDs:        cfg.EnvironmentIDs,
+			ServiceIDs:            cfg.ServiceIDs,
+			ServiceNames:          cfg.ServiceNames,
+			EnvironmentServiceIDs: cfg.EnvironmentServiceIDs,
+			Projects:              cfg.Projects,
+			Statuses:              cfg.Statuses,
+			DryRun:                cfg.DryRun,
+			Wait:                  cfg.Wait,
+			Concurrency:           cfg.Concurrency,
+			MaxRetries:            cfg.Retry.MaxRetries,
+			Timeout:               cfg.Timeout.String(),
+			Deadline:              cfg.Deadline.String(),
+		},
+		Summary: summary,
+	}
+
+	if cfg.ReportFormat == railflush.ReportFormatNDJSON {
+		f, err := os.OpenFile(cfg.ReportPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return fmt.Errorf("opening report file: %w", err)
+		}
+		if err := json.NewEncoder(f).Encode(record); err != nil {
+			f.Close()
+			return fmt.Errorf("writing report file: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("writing report file: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling report: %w", err)
+	}
+	if err := os.WriteFile(cfg.ReportPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing report file: %w", err)
+	}
+	return nil
+}
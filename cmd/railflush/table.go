@@ -0,0 +1,56 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/berry/railflush"
+)
+
+// writeTable writes the run's services as an aligned table for -output table,
+// sorted by service ID. Project and environment columns are only shown when
+// the run covered more than one.
+func writeTable(w io.Writer, summary railflush.Summary) error {
+	services := slices.Clone(summary.Services)
+	slices.SortStableFunc(services, func(a, b railflush.ServiceResult) int {
+		return cmp.Or(cmp.Compare(a.ServiceID, b.ServiceID), cmp.Compare(a.ProjectID, b.ProjectID), cmp.Compare(a.EnvironmentID, b.EnvironmentID))
+	})
+	var projects, environments []string
+	for _, r := range services {
+		if !slices.Contains(projects, r.ProjectID) {
+			projects = append(projects, r.ProjectID)
+		}
+		if !slices.Contains(environments, r.EnvironmentID) {
+			environments = append(environments, r.EnvironmentID)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(service, project, environment, deployment, status, duration, errMsg string) {
+		fmt.Fprint(tw, service)
+		if len(projects) > 1 {
+			fmt.Fprint(tw, "\t"+project)
+		}
+		if len(environments) > 1 {
+			fmt.Fprint(tw, "\t"+environment)
+		}
+		fmt.Fprintf(tw, "\t%s\t%s\t%s", deployment, status, duration)
+		if errMsg != "" {
+			fmt.Fprint(tw, "\t"+errMsg)
+		}
+		fmt.Fprintln(tw)
+	}
+	row("SERVICE", "PROJECT", "ENVIRONMENT", "DEPLOYMENT", "STATUS", "DURATION", "ERROR")
+	for _, r := range services {
+		deployment := r.DeploymentID
+		if deployment == "" {
+			deployment = "—"
+		}
+		row(r.ServiceID, r.ProjectID, r.EnvironmentID, deployment, r.Status, (time.Duration(r.DurationMS) * time.Millisecond).String(), r.Error)
+	}
+	return tw.Flush()
+}
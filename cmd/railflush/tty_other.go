@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package main
+
+import "os"
+
+// isTerminal reports whether f is an interactive terminal, e.g. one that can
+// answer a confirmation prompt. Without a terminal check for this platform it
+// settles for a character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,873 @@
+package railflush
+
+import (
+	"cmp"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Token types selectable with -token-type, deciding how the token is sent.
+const (
+	// TokenTypeAccount is an account or team token, sent as a bearer token.
+	TokenTypeAccount = "account"
+	// TokenTypeProject is a project token, scoped to one environment and
+	// sent in a Project-Access-Token header.
+	TokenTypeProject = "project"
+)
+
+// Report file formats selectable with -report-format.
+const (
+	ReportFormatJSON   = "json"
+	ReportFormatNDJSON = "ndjson"
+)
+
+// Config holds all configuration loaded from flags and environment variables.
+type Config struct {
+	APIToken              string
+	TokenType             string
+	APIURL                string
+	Proxy                 *url.URL
+	RootCAs               *x509.CertPool
+	InsecureSkipVerify    bool
+	UserAgent             string
+	ServiceIDs            []string
+	ServiceNames          []string
+	All                   bool
+	Yes                   bool
+	NoServiceCache        bool
+	NoBatch               bool
+	DeploymentIDs         map[string]string
+	Only                  []string
+	Skip                  []string
+	NewestOnly            bool
+	ProjectID             string
+	EnvironmentIDs        []string
+	EnvironmentServiceIDs map[string][]string
+	Projects              []ProjectGroup
+	Timeout               time.Duration
+	ServiceTimeouts       map[string]time.Duration
+	MaxIdleConnsPerHost   int
+	IdleConnTimeout       time.Duration
+	HTTP2                 bool
+	Deadline              time.Duration
+	Concurrency           int
+	Delay                 time.Duration
+	Spread                time.Duration
+	Interval              time.Duration
+	Retry                 RetryPolicy
+	RetryBudget           int
+	DryRun                bool
+	FailFast              bool
+	MaxFailures           int
+	Rollback              bool
+	Preflight             bool
+	CheckPermissions      bool
+	Output                string
+	JSONPretty            bool
+	JSONFields            []string
+	ReportPath            string
+	ReportFormat          string
+	Wait                  bool
+	WaitTimeout           time.Duration
+	HealthyStatuses       []string
+	LogsOnFailure         int
+	Statuses              []string
+	RestartIf             []string
+	SlackWebhooks         []string
+	DiscordWebhooks       []string
+	WebhookURLs           []string
+	WebhookTemplate       *template.Template
+	PushgatewayURL        string
+	OTLPEndpoint          string
+	OTLPHeaders           map[string]string
+	LogFormat             string
+	LogTo                 string
+	Action                DeploymentAction
+	ShowVersion           bool
+	PrintConfig           bool
+	List                  bool
+	Quiet                 bool
+	SummaryOnly           bool
+	Progress              string
+	Verbose               int
+	NoEmoji               bool
+	RetryEmpty            int
+	DeploymentsLimit      int
+	MaxAge                time.Duration
+	Since                 time.Time
+	Until                 time.Time
+	DetectInProgress      bool
+	RequireHealthy        bool
+	PreRestartCmd         string
+	PostRestartCmd        string
+	// Warnings lists problems LoadConfig worked around, such as repeated
+	// service IDs, for the caller to log once logging is set up.
+	Warnings []string
+	// Confirm, when set, is called with the services about to be restarted
+	// before any of them is; an error stops the run. It is not called with
+	// DryRun. LoadConfig leaves it nil.
+	Confirm func(ctx context.Context, services []PlannedService) error
+	// OnResult, when set, is called with each service's result as soon as
+	// it is known, from one goroutine at a time. LoadConfig leaves it nil.
+	OnResult func(ServiceResult)
+}
+
+// ErrConfig is matched (with errors.Is) by LoadConfig's errors for an invalid
+// or incomplete configuration.
+var ErrConfig = errors.New("invalid configuration")
+
+// ConfigError lists every problem LoadConfig found in the configuration.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string        { return strings.Join(e.Problems, "; ") }
+func (e *ConfigError) Is(target error) bool { return target == ErrConfig }
+
+// LoadConfig parses command-line flags and reads and validates configuration
+// from environment variables, falling back to the -config file when given.
+// Every problem found is reported in the returned error, not just the first.
+func LoadConfig(args []string) (Config, error) {
+	fs := flag.NewFlagSet("railflush", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for each API request")
+	maxIdleConns := fs.Int("max-idle-conns-per-host", 0, "idle HTTP connections kept open per host for reuse (0 means one per -concurrency worker)")
+	idleConnTimeout := fs.Duration("idle-conn-timeout", defaultIdleConnTimeout, "how long an idle HTTP connection is kept open for reuse")
+	deadline := fs.Duration("deadline", 0, "deadline for the whole run (0 disables)")
+	concurrency := fs.Int("concurrency", 4, "number of services to restart in parallel")
+	delay := fs.Duration("delay", 0, "pause between starting each service (0 disables)")
+	interval := fs.Duration("interval", 0, "run again every interval until interrupted (0 runs once)")
+	spread := fs.Duration("spread", 0, "start services at random times within this window (0 disables)")
+	rate := fs.Float64("rate", 0, "maximum API requests per second across all workers (0 disables)")
+	maxRetries := fs.Int("max-retries", 3, "maximum retries for transient API failures")
+	retryBudget := fs.Int("retry-budget", 0, "maximum retries across the whole run; once spent, failures are not retried (0 for no limit)")
+	backoffBase := fs.Duration("backoff-base", retryBaseDelay, "backoff before the first retry")
+	backoffMax := fs.Duration("backoff-max", retryMaxDelay, "longest backoff between retries")
+	backoffFactor := fs.Float64("backoff-factor", retryFactor, "how much the backoff grows with each retry")
+	backoffJitter := fs.Bool("backoff-jitter", true, "randomize each backoff between half of it and all of it")
+	actionRetries := fs.Int("action-retries", 1, "maximum retries of a failed restart or redeploy (may repeat it if a response was lost)")
+	retryEmpty := fs.Int("retry-empty", 0, "extra attempts when a service has no matching deployment yet")
+	actionName := fs.String("action", "restart", "operation to trigger: restart or redeploy")
+	deploymentsLimit := fs.Int("deployments-limit", DefaultDeploymentsLimit, "how many of a service's deployments to fetch; the newest by createdAt is used")
+	sinceFlag := fs.String("since", "", "only restart services whose deployment was created at or after this time (RFC 3339, a date, or a duration ago such as 24h)")
+	untilFlag := fs.String("until", "", "only restart services whose deployment was created at or before this time (same formats as -since)")
+	maxAge := fs.Duration("max-age", 0, "skip services whose deployment was created longer ago than this (0 disables)")
+	rollback := fs.Bool("rollback", false, "act on the previous matching deployment instead of the latest")
+	dryRun := fs.Bool("dry-run", false, "look up deployments but do not restart them")
+	failFast := fs.Bool("fail-fast", false, "stop the run, canceling services in flight, as soon as one service fails")
+	maxFailures := fs.Int("max-failures", 0, "abort the run once this many services have failed (0 means unlimited)")
+	tokenFile := fs.String("token-file", "", "read the API token from this file (overrides RAILWAY_API_TOKEN_FILE)")
+	tokenType := fs.String("token-type", os.Getenv("RAILWAY_TOKEN_TYPE"), "account (account or team token) or project (project token); defaults to project for RAILWAY_TOKEN and account otherwise")
+	checkPermissions := fs.Bool("check-permissions", false, "dry run that also checks the token's role allows restarting each service (implies -dry-run)")
+	preRestartCmd := fs.String("pre-restart-cmd", "", "shell command to run before each service's restart; if it fails, the service is not restarted")
+	postRestartCmd := fs.String("post-restart-cmd", "", "shell command to run after each service's restart, whether or not it succeeded")
+	runPreflight := fs.Bool("preflight", false, "verify the project, environments and services exist before restarting")
+	configPath := fs.String("config", "", "path to a YAML or JSON config file")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and exit without restarting")
+	showVersion := fs.Bool("version", false, "print version information and exit")
+	list := fs.Bool("list", false, "list every service in each environment with its latest deployment, and exit without restarting")
+	outputFormat := fs.String("output", OutputText, "output format: text, json, ndjson or table")
+	jsonPretty := fs.Bool("json-pretty", false, "indent -output json for reading in a terminal")
+	jsonFieldList := fs.String("json-fields", "", "comma-separated per-service fields to include in -output json/ndjson, in order, e.g. service_id,deployment_id,status,duration_ms")
+	reportPath := fs.String("report", "", "write a JSON record of the run to this file")
+	reportFormat := fs.String("report-format", ReportFormatJSON, "report file format: json (overwrite) or ndjson (append)")
+	wait := fs.Bool("wait", false, "wait for each restarted deployment to become healthy")
+	healthyStatusList := fs.String("healthy-status", strings.Join(defaultHealthyStatuses, ","), "comma-separated statuses at which -wait considers a deployment healthy, e.g. SUCCESS,SLEEPING")
+	waitTimeout := fs.Duration("wait-timeout", 5*time.Minute, "how long -wait waits for each deployment")
+	logsOnFailure := fs.Bool("logs-on-failure", false, "with -wait, print the last log lines of deployments that end in a failed status")
+	logLines := fs.Int("log-lines", 20, "number of log lines -logs-on-failure prints")
+	statusList := fs.String("status", strings.Join(defaultStatuses, ","), "comma-separated deployment statuses eligible for restart")
+	restartIfList := fs.String("restart-if", "", "comma-separated statuses, e.g. CRASHED,SLEEPING: only restart services whose latest deployment has one, skipping healthy ones")
+	all := fs.Bool("all", false, "restart every service in each environment, ignoring SERVICE_IDS and SERVICE_NAMES")
+	requireHealthy := fs.Bool("require-healthy-before-restart", false, "fail a service instead of acting on it when its newest deployment, in any status, is not one of -healthy-status")
+	detectInProgress := fs.Bool("detect-in-progress", false, "when a service has no matching deployment, skip it with a distinct message if its newest deployment is still building or deploying")
+	http2 := fs.Bool("http2", false, "offer only HTTP/2 when connecting over TLS, and warn if the API answers over HTTP/1.1")
+	userAgent := fs.String("user-agent", "", "User-Agent header for Railway API requests (default railflush/<version>)")
+	yes := fs.Bool("yes", false, "restart without asking for confirmation (required when stdin is not a terminal)")
+	fs.BoolVar(yes, "assume-yes", false, "alias for -yes")
+	noServiceCache := fs.Bool("no-service-cache", false, "query a project's services again for every environment when resolving names")
+	noBatch := fs.Bool("no-batch", false, "look up each service's deployments with its own query instead of batching them")
+	servicesFile := fs.String("services-file", "", "read service IDs from this file, one per line (# starts a comment), in addition to SERVICE_IDS")
+	serviceNameList := fs.String("service-name", "", "comma-separated service names to resolve to IDs (overrides SERVICE_NAMES)")
+	deploymentIDList := fs.String("deployment-id", "", "deployment IDs to act on instead of looking them up: service=deployment pairs, or one per SERVICE_IDS entry (overrides DEPLOYMENT_IDS)")
+	onlyList := fs.String("only", "", "comma-separated service IDs to restart, out of those configured")
+	skipList := fs.String("skip", "", "comma-separated service IDs to leave out of this run")
+	newestOnly := fs.Bool("newest-only", false, "restart only the service whose latest deployment is the newest of all configured services")
+	var projects projectFlag
+	fs.Var(&projects, "project", "JSON project group {project_id, environment_id(s), service_ids, service_names} to restart; repeatable")
+	var verbose verbosity
+	fs.Var(&verbose, "v", "log GraphQL requests and responses; repeat (-v -v) to add timings")
+	fs.Var(&verbose, "verbose", "same as -v; accepts a level, e.g. -verbose=2")
+	quiet := fs.Bool("quiet", false, "only log errors and the final summary")
+	summaryOnly := fs.Bool("summary-only", false, "print nothing but a one-line summary on stdout, not even errors; the exit code tells the outcome")
+	noEmoji := fs.Bool("no-emoji", os.Getenv("NO_COLOR") != "", "use ASCII tags instead of emoji in text logs (default true when NO_COLOR is set)")
+	progressMode := fs.String("progress", ProgressAuto, "progress display while services run: auto, bar, lines (a line as each service finishes and every 10s) or none")
+	logTo := fs.String("log-to", logToStdout, "where informational and progress lines go: stdout or stderr (warnings and errors always go to stderr)")
+	logFormat := fs.String("log-format", logFormatText, "log format: text or json")
+	apiURL := fs.String("api-url", "", "Railway GraphQL endpoint (overrides RAILWAY_API_URL)")
+	proxyURL := fs.String("proxy", "", "proxy URL for all requests (overrides HTTPS_PROXY/HTTP_PROXY)")
+	caCert := fs.String("ca-cert", "", "PEM file of CA certificates to trust in addition to the system ones")
+	insecureSkipVerify := fs.Bool("insecure-skip-verify", false, "do not verify TLS certificates (for testing only; exposes the API token)")
+	slackWebhook := fs.String("slack-webhook", os.Getenv("SLACK_WEBHOOK_URL"), "comma-separated Slack incoming webhook URLs for run summaries")
+	discordWebhook := fs.String("discord-webhook", os.Getenv("DISCORD_WEBHOOK_URL"), "comma-separated Discord webhook URLs for run summaries")
+	webhookURL := fs.String("webhook-url", os.Getenv("WEBHOOK_URL"), "comma-separated URLs to post a templated JSON run summary to")
+	webhookTemplate := fs.String("webhook-template", cmp.Or(os.Getenv("WEBHOOK_TEMPLATE"), "default"), "-webhook-url payload: a built-in template (default, text, pagerduty), @file, or Go text/template source")
+	pushgatewayURL := fs.String("pushgateway-url", "", "Prometheus Pushgateway URL to push run metrics to")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if *showVersion {
+		// Nothing else is needed to print the version, so skip validation.
+		return Config{ShowVersion: true}, nil
+	}
+
+	var problems []string
+	invalid := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if *timeout <= 0 {
+		invalid("-timeout must be positive")
+	}
+	if *maxIdleConns < 0 {
+		invalid("-max-idle-conns-per-host must not be negative")
+	}
+	if *idleConnTimeout <= 0 {
+		invalid("-idle-conn-timeout must be positive")
+	}
+	if *deadline < 0 {
+		invalid("-deadline must not be negative")
+	}
+	if *concurrency < 1 {
+		invalid("-concurrency must be at least 1")
+	}
+	if *maxRetries < 0 {
+		invalid("-max-retries must not be negative")
+	}
+	if *retryBudget < 0 {
+		invalid("-retry-budget must not be negative")
+	}
+	if *actionRetries < 0 {
+		invalid("-action-retries must not be negative")
+	}
+	switch {
+	case *backoffBase <= 0:
+		invalid("-backoff-base must be positive")
+	case *backoffBase > *backoffMax:
+		invalid("-backoff-base %s must not be longer than -backoff-max %s", *backoffBase, *backoffMax)
+	}
+	if !(*backoffFactor >= 1) {
+		invalid("-backoff-factor must be at least 1")
+	}
+	if *rate < 0 {
+		invalid("-rate must not be negative")
+	}
+	if *delay < 0 {
+		invalid("-delay must not be negative")
+	}
+	if *interval < 0 {
+		invalid("-interval must not be negative")
+	}
+	if *spread < 0 {
+		invalid("-spread must not be negative")
+	}
+	if *maxFailures < 0 {
+		invalid("-max-failures must not be negative")
+	}
+	if *retryEmpty < 0 {
+		invalid("-retry-empty must not be negative")
+	}
+	if *maxAge < 0 {
+		invalid("-max-age must not be negative")
+	}
+	now := time.Now()
+	since, err := parseTimeBound(*sinceFlag, now)
+	if err != nil {
+		invalid("-since: %v", err)
+	}
+	until, err := parseTimeBound(*untilFlag, now)
+	if err != nil {
+		invalid("-until: %v", err)
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		invalid("-until must not be before -since")
+	}
+	switch {
+	case *deploymentsLimit < 1:
+		invalid("-deployments-limit must be at least 1")
+	case *rollback && *deploymentsLimit < 2:
+		invalid("-rollback needs -deployments-limit of at least 2")
+	}
+	if !slices.Contains([]string{OutputText, OutputJSON, OutputNDJSON, OutputTable}, *outputFormat) {
+		invalid("-output must be %q, %q, %q or %q", OutputText, OutputJSON, OutputNDJSON, OutputTable)
+	}
+	if *summaryOnly && (*outputFormat != OutputText || verbose > 0) {
+		invalid("-summary-only cannot be combined with -output %s or -v", *outputFormat)
+	}
+	if *jsonPretty && *outputFormat != OutputJSON {
+		invalid("-json-pretty requires -output %s", OutputJSON)
+	}
+	jsonFields := trimIDs(strings.Split(*jsonFieldList, ","))
+	if len(jsonFields) > 0 {
+		if *outputFormat != OutputJSON && *outputFormat != OutputNDJSON {
+			invalid("-json-fields requires -output %s or %s", OutputJSON, OutputNDJSON)
+		}
+		known := serviceResultFields()
+		for _, f := range jsonFields {
+			if !slices.Contains(known, f) {
+				invalid("-json-fields: unknown field %q (valid: %s)", f, strings.Join(known, ", "))
+			}
+		}
+	}
+	if *reportFormat != ReportFormatJSON && *reportFormat != ReportFormatNDJSON {
+		invalid("-report-format must be %q or %q", ReportFormatJSON, ReportFormatNDJSON)
+	}
+	if *waitTimeout <= 0 {
+		invalid("-wait-timeout must be positive")
+	}
+	if *logLines < 1 {
+		invalid("-log-lines must be at least 1")
+	}
+	if *logsOnFailure && !*wait {
+		invalid("-logs-on-failure requires -wait")
+	}
+	var failureLogLines int
+	if *logsOnFailure {
+		failureLogLines = *logLines
+	}
+	if !slices.Contains([]string{ProgressAuto, ProgressBar, ProgressLines, ProgressNone}, *progressMode) {
+		invalid("-progress must be %q, %q, %q or %q", ProgressAuto, ProgressBar, ProgressLines, ProgressNone)
+	}
+	if *logTo != logToStdout && *logTo != logToStderr {
+		invalid("-log-to must be %q or %q", logToStdout, logToStderr)
+	}
+	if *logFormat != logFormatText && *logFormat != logFormatJSON {
+		invalid("-log-format must be %q or %q", logFormatText, logFormatJSON)
+	}
+	action, ok := DeploymentActions[*actionName]
+	if !ok {
+		invalid("-action must be %q or %q", "restart", "redeploy")
+	}
+	statuses, err := parseStatuses(*statusList)
+	if err != nil {
+		invalid("-status: %v", err)
+	}
+	healthyStatuses, err := parseStatuses(*healthyStatusList)
+	if err != nil {
+		invalid("-healthy-status: %v", err)
+	}
+	var restartIf []string
+	if *restartIfList != "" {
+		if restartIf, err = parseStatuses(*restartIfList); err != nil {
+			invalid("-restart-if: %v", err)
+		}
+		if *rollback {
+			invalid("-restart-if cannot be combined with -rollback")
+		}
+		if *requireHealthy {
+			invalid("-restart-if cannot be combined with -require-healthy-before-restart")
+		}
+		// The latest deployment is looked for among both, so a healthy one
+		// is found and skipped rather than a degraded one behind it.
+		for _, s := range restartIf {
+			if !slices.Contains(statuses, s) {
+				statuses = append(statuses, s)
+			}
+		}
+	}
+
+	var file fileConfig
+	if *configPath != "" {
+		if file, err = loadConfigFile(*configPath); err != nil {
+			invalid("%v", err)
+		}
+	}
+
+	token := os.Getenv("RAILWAY_API_TOKEN")
+	tokenPath := *tokenFile
+	if tokenPath == "" {
+		tokenPath = os.Getenv("RAILWAY_API_TOKEN_FILE")
+	}
+	if tokenPath != "" {
+		if token != "" {
+			invalid("RAILWAY_API_TOKEN and a token file (-token-file or RAILWAY_API_TOKEN_FILE) are mutually exclusive")
+		} else if token, err = readTokenFile(tokenPath); err != nil {
+			invalid("%v", err)
+		}
+	}
+	if token == "" {
+		token = file.APIToken
+	}
+	// RAILWAY_TOKEN is where the Railway CLI reads project tokens from.
+	defaultTokenType := TokenTypeAccount
+	if token == "" && tokenPath == "" {
+		if token = os.Getenv("RAILWAY_TOKEN"); token != "" {
+			defaultTokenType = TokenTypeProject
+		}
+	}
+	if token == "" && tokenPath == "" {
+		invalid("RAILWAY_API_TOKEN (or RAILWAY_TOKEN for a project token) is required")
+	}
+	resolvedTokenType := cmp.Or(*tokenType, defaultTokenType)
+	if resolvedTokenType != TokenTypeAccount && resolvedTokenType != TokenTypeProject {
+		invalid("-token-type must be %q or %q", TokenTypeAccount, TokenTypeProject)
+	}
+
+	var serviceIDs, serviceNames []string
+	if raw := os.Getenv("SERVICE_IDS"); raw != "" {
+		serviceIDs = strings.Split(raw, ",")
+	} else {
+		serviceIDs = file.ServiceIDs
+	}
+	servicesFileRead := false
+	if *servicesFile != "" {
+		ids, err := readServicesFile(*servicesFile)
+		if err != nil {
+			invalid("-services-file: %v", err)
+		}
+		servicesFileRead = err == nil
+		for _, id := range ids {
+			if !slices.Contains(serviceIDs, id) {
+				serviceIDs = append(serviceIDs, id)
+			}
+		}
+	}
+	if *serviceNameList != "" {
+		serviceNames = strings.Split(*serviceNameList, ",")
+	} else if raw := os.Getenv("SERVICE_NAMES"); raw != "" {
+		serviceNames = strings.Split(raw, ",")
+	} else {
+		serviceNames = file.ServiceNames
+	}
+
+	specs := []projectSpec(projects)
+	if len(specs) == 0 {
+		specs = file.Projects
+	}
+	// -list shows every service, so the configured ones do not matter.
+	if *list {
+		*all = true
+	}
+	var groups []ProjectGroup
+	for i, spec := range specs {
+		g := spec.group()
+		for _, problem := range g.problems(!*all) {
+			invalid("project %d: %s", i+1, problem)
+		}
+		groups = append(groups, g)
+	}
+
+	envServices := file.EnvironmentServiceIDs
+	if raw := os.Getenv("ENVIRONMENT_SERVICE_IDS"); raw != "" {
+		envServices = nil
+		if err := json.Unmarshal([]byte(raw), &envServices); err != nil {
+			invalid("ENVIRONMENT_SERVICE_IDS must be a JSON object mapping environment IDs to service ID lists: %v", err)
+		}
+	}
+	envServices = trimEnvironmentServices(envServices)
+
+	var serviceTimeouts map[string]time.Duration
+	for _, id := range slices.Sorted(maps.Keys(file.ServiceTimeouts)) {
+		d, err := time.ParseDuration(file.ServiceTimeouts[id])
+		if err != nil || d <= 0 {
+			invalid("service_timeouts: %s must be a positive duration such as 2m, not %q", id, file.ServiceTimeouts[id])
+			continue
+		}
+		if serviceTimeouts == nil {
+			serviceTimeouts = map[string]time.Duration{}
+		}
+		serviceTimeouts[strings.TrimSpace(id)] = d
+	}
+
+	// With project groups the top-level project only takes part if it has
+	// services of its own.
+	serviceIDs, serviceNames = trimIDs(serviceIDs), trimIDs(serviceNames)
+	var warnings []string
+	var dups []string
+	if serviceIDs, dups = dedupeIDs(serviceIDs); len(dups) > 0 {
+		warnings = append(warnings, fmt.Sprintf("SERVICE_IDS lists %s more than once; each is restarted once", strings.Join(dups, ", ")))
+	}
+	if serviceNames, dups = dedupeIDs(serviceNames); len(dups) > 0 {
+		warnings = append(warnings, fmt.Sprintf("SERVICE_NAMES lists %s more than once; each is restarted once", strings.Join(dups, ", ")))
+	}
+	if *all {
+		// Every service is restarted, so the configured lists do not matter.
+		serviceIDs, serviceNames, envServices = nil, nil, nil
+	}
+	topLevel := len(groups) == 0 || len(serviceIDs) > 0 || len(serviceNames) > 0 || len(envServices) > 0
+	switch {
+	case !topLevel, len(envServices) > 0, *all:
+	case *servicesFile != "" && !servicesFileRead:
+	case *servicesFile != "" && len(serviceIDs) == 0 && len(serviceNames) == 0:
+		invalid("-services-file %s lists no service IDs, and SERVICE_IDS adds none", *servicesFile)
+	case serviceIDs == nil && serviceNames == nil && os.Getenv("SERVICE_IDS") == "":
+		invalid("SERVICE_IDS (or SERVICE_NAMES) is required")
+	case len(serviceIDs) == 0 && len(serviceNames) == 0:
+		invalid("SERVICE_IDS must contain at least one service ID")
+	}
+
+	var deploymentIDs map[string]string
+	rawDeploymentIDs := *deploymentIDList
+	if rawDeploymentIDs == "" {
+		rawDeploymentIDs = os.Getenv("DEPLOYMENT_IDS")
+	}
+	if rawDeploymentIDs != "" {
+		if deploymentIDs, err = parseDeploymentIDs(rawDeploymentIDs, serviceIDs, len(serviceNames) == 0 && len(envServices) == 0); err != nil {
+			invalid("-deployment-id: %v", err)
+		}
+	}
+
+	endpoint := *apiURL
+	if endpoint == "" {
+		endpoint = os.Getenv("RAILWAY_API_URL")
+	}
+	if endpoint == "" {
+		endpoint = defaultAPIURL
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("API URL %q must be an absolute http(s) URL", endpoint)
+	} else if *http2 && u.Scheme != "https" {
+		invalid("-http2 requires an https API URL, since HTTP/2 is only negotiated over TLS")
+	}
+
+	if *pushgatewayURL != "" {
+		if u, err := url.Parse(*pushgatewayURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("-pushgateway-url %q must be an absolute http(s) URL", *pushgatewayURL)
+		}
+	}
+
+	var webhookTmpl *template.Template
+	webhookURLs := trimIDs(strings.Split(*webhookURL, ","))
+	if len(webhookURLs) > 0 {
+		for _, raw := range webhookURLs {
+			if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				invalid("-webhook-url must be absolute http(s) URLs")
+				break
+			}
+		}
+		if webhookTmpl, err = parseWebhookTemplate(*webhookTemplate); err != nil {
+			invalid("-webhook-template: %v", err)
+		}
+	}
+
+	var proxy *url.URL
+	if *proxyURL != "" {
+		if proxy, err = url.Parse(*proxyURL); err != nil || proxy.Host == "" {
+			invalid("-proxy %q must be an absolute URL", *proxyURL)
+		}
+	}
+
+	var rootCAs *x509.CertPool
+	if *caCert != "" {
+		if rootCAs, err = loadCertPool(*caCert); err != nil {
+			invalid("-ca-cert: %v", err)
+		}
+	}
+	if *insecureSkipVerify {
+		warnings = append(warnings, "-insecure-skip-verify is set: TLS certificates are NOT verified, so anyone in the path can read the API token. Use it for testing only; it applies to API requests only")
+	}
+
+	// Explicit settings win over the IDs Railway injects into every service.
+	projectID := os.Getenv("PROJECT_ID")
+	if projectID == "" {
+		projectID = file.ProjectID
+	}
+	if projectID == "" {
+		projectID = os.Getenv("RAILWAY_PROJECT_ID")
+	}
+	if projectID == "" && topLevel {
+		invalid("PROJECT_ID (or RAILWAY_PROJECT_ID) is required")
+	}
+
+	var environmentIDs []string
+	switch {
+	case os.Getenv("ENVIRONMENT_IDS") != "":
+		environmentIDs = strings.Split(os.Getenv("ENVIRONMENT_IDS"), ",")
+	case os.Getenv("ENVIRONMENT_ID") != "":
+		environmentIDs = []string{os.Getenv("ENVIRONMENT_ID")}
+	case file.EnvironmentIDs != nil:
+		environmentIDs = file.EnvironmentIDs
+	case file.EnvironmentID != "":
+		environmentIDs = []string{file.EnvironmentID}
+	case len(envServices) == 0:
+		environmentIDs = []string{os.Getenv("RAILWAY_ENVIRONMENT_ID")}
+	}
+	if environmentIDs, dups = dedupeIDs(trimIDs(environmentIDs)); len(dups) > 0 {
+		warnings = append(warnings, fmt.Sprintf("ENVIRONMENT_IDS lists %s more than once; each is used once", strings.Join(dups, ", ")))
+	}
+	environmentIDs = withMappedEnvironments(environmentIDs, envServices)
+	if !topLevel {
+		projectID, environmentIDs, envServices = "", nil, nil
+	}
+	if len(environmentIDs) == 0 && topLevel {
+		invalid("ENVIRONMENT_ID or ENVIRONMENT_IDS (or RAILWAY_ENVIRONMENT_ID) is required")
+	}
+	// A deployment belongs to a single environment of a single project.
+	if deploymentIDs != nil && (len(environmentIDs) > 1 || len(groups) > 0) {
+		invalid("-deployment-id requires a single environment and no -project groups")
+	}
+	if deploymentIDs != nil && *newestOnly {
+		invalid("-newest-only cannot be combined with -deployment-id")
+	}
+
+	if len(problems) > 0 {
+		return Config{}, &ConfigError{Problems: problems}
+	}
+
+	retry := RetryPolicy{
+		MaxRetries:    *maxRetries,
+		ActionRetries: min(*actionRetries, *maxRetries),
+		Limiter:       NewRateLimiter(*rate),
+		BaseDelay:     *backoffBase,
+		MaxDelay:      *backoffMax,
+		Factor:        *backoffFactor,
+		NoJitter:      !*backoffJitter,
+	}
+	return Config{
+		APIToken:              token,
+		TokenType:             resolvedTokenType,
+		APIURL:                endpoint,
+		Proxy:                 proxy,
+		RootCAs:               rootCAs,
+		InsecureSkipVerify:    *insecureSkipVerify,
+		UserAgent:             strings.TrimSpace(*userAgent),
+		HTTP2:                 *http2,
+		DetectInProgress:      *detectInProgress,
+		RequireHealthy:        *requireHealthy,
+		PreRestartCmd:         *preRestartCmd,
+		PostRestartCmd:        *postRestartCmd,
+		ServiceIDs:            serviceIDs,
+		Warnings:              warnings,
+		ServiceNames:          serviceNames,
+		All:                   *all,
+		Yes:                   *yes,
+		NoServiceCache:        *noServiceCache,
+		NoBatch:               *noBatch,
+		DeploymentIDs:         deploymentIDs,
+		Only:                  trimIDs(strings.Split(*onlyList, ",")),
+		Skip:                  trimIDs(strings.Split(*skipList, ",")),
+		NewestOnly:            *newestOnly,
+		ProjectID:             projectID,
+		EnvironmentIDs:        environmentIDs,
+		EnvironmentServiceIDs: envServices,
+		Projects:              groups,
+		Timeout:               *timeout,
+		ServiceTimeouts:       serviceTimeouts,
+		MaxIdleConnsPerHost:   *maxIdleConns,
+		IdleConnTimeout:       *idleConnTimeout,
+		Deadline:              *deadline,
+		Concurrency:           *concurrency,
+		Delay:                 *delay,
+		Spread:                *spread,
+		Interval:              *interval,
+		Retry:                 retry,
+		RetryBudget:           *retryBudget,
+		DryRun:                *dryRun || *checkPermissions,
+		FailFast:              *failFast,
+		MaxFailures:           *maxFailures,
+		Rollback:              *rollback,
+		Preflight:             *runPreflight,
+		CheckPermissions:      *checkPermissions,
+		Output:                *outputFormat,
+		JSONPretty:            *jsonPretty,
+		JSONFields:            jsonFields,
+		ReportPath:            *reportPath,
+		ReportFormat:          *reportFormat,
+		Wait:                  *wait,
+		WaitTimeout:           *waitTimeout,
+		HealthyStatuses:       healthyStatuses,
+		LogsOnFailure:         failureLogLines,
+		Statuses:              statuses,
+		RestartIf:             restartIf,
+		SlackWebhooks:         trimIDs(strings.Split(*slackWebhook, ",")),
+		WebhookURLs:           webhookURLs,
+		WebhookTemplate:       webhookTmpl,
+		DiscordWebhooks:       trimIDs(strings.Split(*discordWebhook, ",")),
+		PushgatewayURL:        *pushgatewayURL,
+		OTLPEndpoint:          otlpTracesEndpoint(),
+		OTLPHeaders:           parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		LogFormat:             *logFormat,
+		LogTo:                 *logTo,
+		Action:                action,
+		Quiet:                 *quiet,
+		SummaryOnly:           *summaryOnly,
+		Progress:              *progressMode,
+		Verbose:               int(verbose),
+		NoEmoji:               *noEmoji,
+		RetryEmpty:            *retryEmpty,
+		DeploymentsLimit:      *deploymentsLimit,
+		MaxAge:                *maxAge,
+		Since:                 since,
+		Until:                 until,
+		PrintConfig:           *printConfig,
+		List:                  *list,
+	}, nil
+}
+
+// verbosity is a flag.Value counting how often -v is given. An explicit level
+// such as -verbose=2 replaces the count.
+type verbosity int
+
+func (v *verbosity) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *verbosity) IsBoolFlag() bool { return true }
+
+func (v *verbosity) Set(s string) error {
+	switch s {
+	case "true":
+		*v++
+	case "false":
+		*v = 0
+	default:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid verbosity level %q", s)
+		}
+		*v = verbosity(n)
+	}
+	return nil
+}
+
+// parseDeploymentIDs parses deployment overrides, given either as
+// service=deployment pairs or as a plain list matched to serviceIDs by
+// position. A plain list is only allowed when onlyIDs reports that serviceIDs
+// are all the services configured.
+func parseDeploymentIDs(raw string, serviceIDs []string, onlyIDs bool) (map[string]string, error) {
+	entries := trimIDs(strings.Split(raw, ","))
+	overrides := make(map[string]string, len(entries))
+	if !strings.Contains(raw, "=") {
+		if !onlyIDs || len(entries) != len(serviceIDs) {
+			return nil, fmt.Errorf("got %d deployment ID(s) for %d service ID(s); use service=deployment pairs to override only some services", len(entries), len(serviceIDs))
+		}
+		for i, id := range entries {
+			overrides[serviceIDs[i]] = id
+		}
+		return overrides, nil
+	}
+
+	for _, entry := range entries {
+		service, deployment, ok := strings.Cut(entry, "=")
+		service, deployment = strings.TrimSpace(service), strings.TrimSpace(deployment)
+		if !ok || service == "" || deployment == "" {
+			return nil, fmt.Errorf("%q is not a service=deployment pair", entry)
+		}
+		if _, dup := overrides[service]; dup {
+			return nil, fmt.Errorf("service %s is given more than once", service)
+		}
+		overrides[service] = deployment
+	}
+	return overrides, nil
+}
+
+// trimEnvironmentServices trims the service IDs mapped to each environment,
+// dropping environments left without any.
+func trimEnvironmentServices(m map[string][]string) map[string][]string {
+	var out map[string][]string
+	for envID, ids := range m {
+		envID, ids = strings.TrimSpace(envID), trimIDs(ids)
+		if envID == "" || len(ids) == 0 {
+			continue
+		}
+		if out == nil {
+			out = map[string][]string{}
+		}
+		out[envID] = append(out[envID], ids...)
+	}
+	return out
+}
+
+// withMappedEnvironments appends the environments of envServices missing from
+// environmentIDs, in sorted order so runs are reproducible.
+func withMappedEnvironments(environmentIDs []string, envServices map[string][]string) []string {
+	for _, envID := range slices.Sorted(maps.Keys(envServices)) {
+		if !slices.Contains(environmentIDs, envID) {
+			environmentIDs = append(environmentIDs, envID)
+		}
+	}
+	return environmentIDs
+}
+
+// readTokenFile reads an API token from path, trimming surrounding whitespace.
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// parseTimeBound parses -since or -until: an RFC 3339 timestamp, a date
+// (midnight UTC), or a duration meaning that long before now. An empty value
+// is the zero time, i.e. no bound.
+func parseTimeBound(raw string, now time.Time) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time (2006-01-02T15:04:05Z), a date (2006-01-02) or a duration ago (24h)", raw)
+}
+
+// readServicesFile reads service IDs from path, one per line. Blank lines and
+// everything after a "#" are ignored.
+func readServicesFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading services file: %w", err)
+	}
+	var ids []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		ids = append(ids, line)
+	}
+	return trimIDs(ids), nil
+}
+
+// dedupeIDs drops repeated IDs, keeping the first of each, and returns the
+// ones that were repeated.
+func dedupeIDs(ids []string) (unique, dups []string) {
+	for _, id := range ids {
+		switch {
+		case !slices.Contains(unique, id):
+			unique = append(unique, id)
+		case !slices.Contains(dups, id):
+			dups = append(dups, id)
+		}
+	}
+	return unique, dups
+}
+
+// trimIDs trims whitespace from each ID and drops empty entries.
+func trimIDs(ids []string) []string {
+	var out []string
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id != "" {
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package railflush
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTrimIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{"nil", nil, nil},
+		{"unchanged", []string{"a", "b"}, []string{"a", "b"}},
+		{"whitespace", []string{" a", "b\t", "\n c \r"}, []string{"a", "b", "c"}},
+		{"blanks", []string{"", "a", "  ", "\t", "b", ""}, []string{"a", "b"}},
+		{"all blank", []string{"", " "}, nil},
+		{"duplicates kept", []string{"a", " a", "a "}, []string{"a", "a", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimIDs(tt.in); !slices.Equal(got, tt.want) {
+				t.Errorf("trimIDs(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupeIDs(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         []string
+		wantUnique []string
+		wantDups   []string
+	}{
+		{"nil", nil, nil, nil},
+		{"no duplicates", []string{"a", "b", "c"}, []string{"a", "b", "c"}, nil},
+		{"first kept", []string{"b", "a", "b"}, []string{"b", "a"}, []string{"b"}},
+		{"repeated dup reported once", []string{"a", "a", "b", "a", "b"}, []string{"a", "b"}, []string{"a", "b"}},
+		{"case sensitive", []string{"a", "A"}, []string{"a", "A"}, nil},
+		{"after trimming", trimIDs([]string{"a", " a ", "", "b", "b\n"}), []string{"a", "b"}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unique, dups := dedupeIDs(tt.in)
+			if !slices.Equal(unique, tt.wantUnique) || !slices.Equal(dups, tt.wantDups) {
+				t.Errorf("dedupeIDs(%q) = %q, %q, want %q, %q", tt.in, unique, dups, tt.wantUnique, tt.wantDups)
+			}
+		})
+	}
+}
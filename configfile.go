@@ -0,0 +1,123 @@
+package railflush
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// fileConfig is the on-disk representation of a -config file.
+type fileConfig struct {
+	APIToken              string              `json:"api_token"`
+	ServiceIDs            []string            `json:"service_ids"`
+	ServiceNames          []string            `json:"service_names"`
+	ProjectID             string              `json:"project_id"`
+	EnvironmentID         string              `json:"environment_id"`
+	EnvironmentIDs        []string            `json:"environment_ids"`
+	EnvironmentServiceIDs map[string][]string `json:"environment_service_ids"`
+	Projects              []projectSpec       `json:"projects"`
+	ServiceTimeouts       map[string]string   `json:"service_timeouts"`
+}
+
+// loadConfigFile reads a YAML, TOML or JSON config file, choosing the format by
+// extension, and expands ${VAR} references in its string values. Unknown keys
+// are rejected to catch typos.
+func loadConfigFile(path string) (fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var doc any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&doc)
+	case ".yaml", ".yml":
+		doc, err = parseYAML(data)
+	case ".toml":
+		doc, err = parseTOML(data)
+	default:
+		return fileConfig{}, fmt.Errorf("config file %s: unsupported extension %q (want .json, .yaml, .yml or .toml)", path, ext)
+	}
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	if doc, err = expandEnvRefs(doc, ""); err != nil {
+		return fileConfig{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return fileConfig{}, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	var fc fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return fileConfig{}, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// envRef matches a ${VAR} reference in a config file value, or an escaped
+// $${VAR} that stands for the literal text ${VAR}.
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnvRefs replaces ${VAR} references in the string values of a parsed
+// config file with the variables' values, so secrets such as the API token
+// can be kept out of the file. path names v's key in errors. Referencing a
+// variable that is not set is an error rather than an empty string; one that
+// is set to "" expands to it. $${VAR} is left as the literal ${VAR}, and a $
+// not followed by a braced name is kept as it is.
+func expandEnvRefs(v any, path string) (any, error) {
+	switch v := v.(type) {
+	case string:
+		var missing []string
+		expanded := envRef.ReplaceAllStringFunc(v, func(ref string) string {
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
+			}
+			name := envRef.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			return value
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("%s references unset environment variable(s) %s", path, strings.Join(missing, ", "))
+		}
+		return expanded, nil
+	case map[string]any:
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			expanded, err := expandEnvRefs(v[key], joinKeyPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			v[key] = expanded
+		}
+	case []any:
+		for i, value := range v {
+			expanded, err := expandEnvRefs(value, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	}
+	return v, nil
+}
+
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
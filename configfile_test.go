@@ -0,0 +1,67 @@
+package railflush
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnvRefs(t *testing.T) {
+	t.Setenv("RF_TOKEN", "s3cret")
+	t.Setenv("RF_EMPTY", "")
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain value", "abc123", "abc123"},
+		{"whole value", "${RF_TOKEN}", "s3cret"},
+		{"inside a value", "Bearer ${RF_TOKEN}!", "Bearer s3cret!"},
+		{"set to empty", "a${RF_EMPTY}b", "ab"},
+		{"escaped", "$${RF_TOKEN}", "${RF_TOKEN}"},
+		{"escaped next to a reference", "$${RF_TOKEN}=${RF_TOKEN}", "${RF_TOKEN}=s3cret"},
+		{"literal $", "pa$$word $5 $RF_TOKEN", "pa$$word $5 $RF_TOKEN"},
+		{"unbraced name", "${RF TOKEN} ${1X}", "${RF TOKEN} ${1X}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnvRefs(tt.in, "api_token")
+			if err != nil {
+				t.Fatalf("expandEnvRefs: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expandEnvRefs(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandEnvRefsNested(t *testing.T) {
+	t.Setenv("RF_TOKEN", "s3cret")
+	var doc any
+	if err := json.Unmarshal([]byte(`{"api_token":"${RF_TOKEN}","projects":[{"service_ids":["${RF_TOKEN}", 1, true]}]}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	got, err := expandEnvRefs(doc, "")
+	if err != nil {
+		t.Fatalf("expandEnvRefs: %v", err)
+	}
+	out, _ := json.Marshal(got)
+	if want := `{"api_token":"s3cret","projects":[{"service_ids":["s3cret",1,true]}]}`; string(out) != want {
+		t.Errorf("expandEnvRefs = %s, want %s", out, want)
+	}
+}
+
+func TestExpandEnvRefsUnset(t *testing.T) {
+	t.Setenv("RF_TOKEN", "s3cret")
+	doc := map[string]any{"projects": []any{map[string]any{"project_id": "${RF_UNSET_A}-${RF_TOKEN}-${RF_UNSET_B}"}}}
+	_, err := expandEnvRefs(doc, "")
+	want := "projects[0].project_id references unset environment variable(s) RF_UNSET_A, RF_UNSET_B"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expandEnvRefs error = %v, want one containing %q", err, want)
+	}
+	// An escaped reference to an unset variable is not an error.
+	if got, err := expandEnvRefs("$${RF_UNSET_A}", "api_token"); err != nil || got != "${RF_UNSET_A}" {
+		t.Errorf("expandEnvRefs = %q, %v, want ${RF_UNSET_A}", got, err)
+	}
+}
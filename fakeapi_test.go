@@ -0,0 +1,172 @@
+package railflush
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Run and the client log every step; keep test output to failures.
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// fakeCall is one GraphQL request received by a fakeAPI.
+type fakeCall struct {
+	Query     string
+	Variables map[string]any
+}
+
+// fakeAPI is a stand-in for Railway's GraphQL API. It answers the
+// deployments, batched deployments, deployment status and restart/redeploy
+// queries from its fields, and records every request.
+type fakeAPI struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls []fakeCall
+
+	// deployments maps service IDs to their deployments; other services have
+	// none.
+	deployments map[string][]Deployment
+	// status answers the deployment status query for the nth poll (from 1) of
+	// deployment id; when nil, every deployment is SUCCESS.
+	status func(id string, n int) Deployment
+	// override, when set, answers a request itself by returning a status code
+	// and body; a zero status code leaves the request to the fake.
+	override func(call fakeCall) (int, string)
+
+	// restartDelay holds each restart mutation for this long.
+	restartDelay time.Duration
+	polls        map[string]int
+}
+
+// newFakeAPI starts a fakeAPI serving the given deployments, closed when the
+// test ends.
+func newFakeAPI(t *testing.T, deployments map[string][]Deployment) *fakeAPI {
+	t.Helper()
+	f := &fakeAPI{deployments: deployments, polls: map[string]int{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// batchAlias matches the per-service variables of a batched deployments query.
+var batchAlias = regexp.MustCompile(`^s[0-9]+$`)
+
+func (f *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	var call fakeCall
+	if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	if f.override != nil {
+		if status, body := f.override(call); status != 0 {
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+			return
+		}
+	}
+
+	var data map[string]any
+	switch q := call.Query; {
+	case strings.Contains(q, "deploymentsBatch"):
+		data = map[string]any{}
+		for alias, id := range call.Variables {
+			if batchAlias.MatchString(alias) {
+				data[alias] = connection(f.deployments[id.(string)])
+			}
+		}
+	case strings.Contains(q, "deployments("):
+		data = map[string]any{"deployments": connection(f.deployments[call.Variables["serviceId"].(string)])}
+	case strings.Contains(q, "deploymentRestart"), strings.Contains(q, "deploymentRedeploy"):
+		time.Sleep(f.restartDelay)
+		data = map[string]any{"deploymentRestart": true, "deploymentRedeploy": map[string]any{"id": "new"}}
+	case strings.Contains(q, "deployment("):
+		id := call.Variables["id"].(string)
+		f.mu.Lock()
+		f.polls[id]++
+		n := f.polls[id]
+		f.mu.Unlock()
+		dep := Deployment{ID: id, Status: "SUCCESS"}
+		if f.status != nil {
+			dep = f.status(id, n)
+		}
+		data = map[string]any{"deployment": dep}
+	default:
+		http.Error(w, "unexpected query: "+q, http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+// connection renders deployments as a GraphQL connection.
+func connection(deployments []Deployment) map[string]any {
+	edges := []map[string]any{}
+	for _, d := range deployments {
+		edges = append(edges, map[string]any{"node": d})
+	}
+	return map[string]any{"edges": edges}
+}
+
+// count returns how many requests' queries contain substr.
+func (f *fakeAPI) count(substr string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		if strings.Contains(c.Query, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+// client returns a Client for f without retries.
+func (f *fakeAPI) client() *Client {
+	return NewClient(f.Client(), f.URL, "token", RetryPolicy{})
+}
+
+// config returns a Config that restarts services in f's project p and
+// environment e, leaving everything else at its zero value.
+func (f *fakeAPI) config(serviceIDs ...string) Config {
+	return Config{
+		APIURL:         f.URL,
+		APIToken:       "token",
+		ProjectID:      "p",
+		EnvironmentIDs: []string{"e"},
+		ServiceIDs:     serviceIDs,
+	}
+}
+
+// successDeployments returns one SUCCESS deployment, dep-<id>, for each
+// service.
+func successDeployments(serviceIDs ...string) map[string][]Deployment {
+	m := map[string][]Deployment{}
+	for _, id := range serviceIDs {
+		m[id] = []Deployment{{ID: "dep-" + id, Status: "SUCCESS", CreatedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}}
+	}
+	return m
+}
+
+// statusesByService returns each result's status keyed by service ID.
+func statusesByService(s Summary) map[string]string {
+	m := map[string]string{}
+	for _, r := range s.Services {
+		m[r.ServiceID] = r.Status
+	}
+	return m
+}
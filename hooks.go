@@ -0,0 +1,66 @@
+package railflush
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hookWaitDelay bounds how long a canceled hook's output is waited for, in
+// case the shell left children holding its pipes open.
+const hookWaitDelay = 5 * time.Second
+
+// postHookTimeout bounds a -post-restart-cmd, which is not stopped when the
+// run is aborted or interrupted.
+const postHookTimeout = 2 * time.Minute
+
+// maxHookOutput caps how much of a hook's output is included in logs.
+const maxHookOutput = 1 << 10
+
+// runHook runs a -pre-restart-cmd or -post-restart-cmd with sh -c, passing
+// the service and deployment in RAILFLUSH_* environment variables on top of
+// railflush's own environment. The hook, and on Unix everything it started,
+// is killed when ctx is canceled. A failing hook's error includes the end of
+// its combined output.
+func runHook(ctx context.Context, command string, cfg Config, t target, result ServiceResult) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.WaitDelay = hookWaitDelay
+	killProcessGroup(cmd)
+	cmd.Env = append(os.Environ(),
+		"RAILFLUSH_SERVICE_ID="+t.ServiceID,
+		"RAILFLUSH_PROJECT_ID="+t.ProjectID,
+		"RAILFLUSH_ENVIRONMENT_ID="+t.EnvironmentID,
+		"RAILFLUSH_DEPLOYMENT_ID="+result.DeploymentID,
+		"RAILFLUSH_ACTION="+cfg.Action.Name,
+		"RAILFLUSH_STATUS="+result.Status,
+		"RAILFLUSH_ERROR="+result.Error,
+	)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	out := strings.TrimSpace(output.String())
+	if len(out) > maxHookOutput {
+		out = "…" + out[len(out)-maxHookOutput:]
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w (%w)", err, ctx.Err())
+		}
+		if out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	if out != "" {
+		slog.Debug(fmt.Sprintf("Hook for service %s printed: %s", t.label, out), Icon("🪝"),
+			"service_id", t.ServiceID, "deployment_id", result.DeploymentID)
+	}
+	return nil
+}
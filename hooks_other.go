@@ -0,0 +1,9 @@
+//go:build !unix
+
+package railflush
+
+import "os/exec"
+
+// killProcessGroup is a no-op where process groups are not available; only
+// the shell itself is killed on cancellation.
+func killProcessGroup(*exec.Cmd) {}
@@ -0,0 +1,60 @@
+package railflush
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ListedService is a service found by List, with its latest deployment.
+type ListedService struct {
+	ProjectID     string `json:"project_id"`
+	EnvironmentID string `json:"environment_id"`
+	ServiceID     string `json:"service_id"`
+	ServiceName   string `json:"service_name"`
+	// DeploymentID, Status and CreatedAt describe the service's newest
+	// deployment in any status; they are empty when it has none.
+	DeploymentID string     `json:"deployment_id,omitempty"`
+	Status       string     `json:"status,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+// List returns every service in each of cfg's project and environments,
+// in the order the API lists them, with its latest deployment. It is the
+// read-only counterpart to Run, for -list: nothing is restarted.
+func List(ctx context.Context, cfg Config) ([]ListedService, error) {
+	api := newAPIClient(newAPIHTTPClient(cfg), cfg)
+	var cache serviceCache
+	if !cfg.NoServiceCache {
+		cache = serviceCache{}
+	}
+
+	var listed []ListedService
+	for _, g := range cfg.groups() {
+		for _, envID := range g.EnvironmentIDs {
+			services, err := cache.services(ctx, api, g.ProjectID, envID)
+			if err != nil {
+				return nil, fmt.Errorf("listing services in environment %s: %w", envID, err)
+			}
+			if len(services) == 0 {
+				continue
+			}
+			ids := make([]string, len(services))
+			for i, svc := range services {
+				ids[i] = svc.ID
+			}
+			deployments, _, err := api.DeploymentsBatch(ctx, g.ProjectID, envID, ids, deploymentStatuses)
+			if err != nil {
+				return nil, fmt.Errorf("looking up deployments in environment %s: %w", envID, err)
+			}
+			for _, svc := range services {
+				s := ListedService{ProjectID: g.ProjectID, EnvironmentID: envID, ServiceID: svc.ID, ServiceName: svc.Name}
+				if deps := deployments[svc.ID]; len(deps) > 0 {
+					s.DeploymentID, s.Status, s.CreatedAt = deps[0].ID, deps[0].Status, &deps[0].CreatedAt
+				}
+				listed = append(listed, s)
+			}
+		}
+	}
+	return listed, nil
+}
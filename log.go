@@ -0,0 +1,177 @@
+package railflush
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Log formats selectable with -log-format.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Log destinations selectable with -log-to.
+const (
+	logToStdout = "stdout"
+	logToStderr = "stderr"
+)
+
+// levelSummary is the level of the end-of-run summary. It sits between Info
+// and Warn so -quiet can drop per-service chatter but keep the summary.
+const levelSummary = slog.LevelInfo + 2
+
+// levelTrace is the level of request timings, logged at -v level 2.
+const levelTrace = slog.LevelDebug - 4
+
+// iconKey is the attribute key carrying the emoji shown by text logs.
+const iconKey = "icon"
+
+// Icon returns the attribute that sets a record's emoji prefix in text logs.
+func Icon(emoji string) slog.Attr {
+	return slog.String(iconKey, emoji)
+}
+
+// NewLogger builds the logger for cfg's -log-format. Text logs are the classic
+// emoji lines on stdout and stderr, or only on stderr with -log-to stderr;
+// JSON logs are written to stderr. -output json and ndjson keep stdout for
+// themselves by discarding text logs entirely, unless they go to stderr.
+// With -quiet only the summary and problems are logged; -v adds GraphQL
+// payloads and a second -v request timings, overriding -quiet. With
+// -summary-only nothing is logged at all.
+func NewLogger(cfg Config) *slog.Logger {
+	if cfg.SummaryOnly {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	level := slog.LevelInfo
+	switch {
+	case cfg.Verbose >= 2:
+		level = levelTrace
+	case cfg.Verbose == 1:
+		level = slog.LevelDebug
+	case cfg.Quiet, cfg.Output == OutputTable:
+		// The table replaces the per-service lines.
+		level = levelSummary
+	}
+
+	if cfg.LogFormat == logFormatJSON {
+		return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				switch {
+				case a.Key == iconKey:
+					return slog.Attr{}
+				case a.Key == slog.LevelKey && a.Value.Any() == levelSummary:
+					return slog.String(slog.LevelKey, slog.LevelInfo.String())
+				case a.Key == slog.LevelKey && a.Value.Any() == levelTrace:
+					return slog.String(slog.LevelKey, "TRACE")
+				}
+				return a
+			},
+		}))
+	}
+	if cfg.logsDiscarded() {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	stdout := io.Writer(os.Stdout)
+	if cfg.LogTo == logToStderr {
+		stdout = os.Stderr
+	}
+	return slog.New(&textHandler{mu: &sync.Mutex{}, level: level, plain: cfg.NoEmoji, stdout: stdout, stderr: os.Stderr})
+}
+
+// logsDiscarded reports whether text logs would end up on stdout alongside
+// -output json or ndjson, and are dropped instead.
+func (cfg Config) logsDiscarded() bool {
+	return (cfg.Output == OutputJSON || cfg.Output == OutputNDJSON) && cfg.LogTo != logToStderr
+}
+
+// textHandler renders each record as a single "<icon> <message>" line, or
+// "[TAG] <message>" when plain is set. Warnings, errors and debug output go to
+// stderr; the mutex keeps lines from concurrent workers from interleaving.
+type textHandler struct {
+	mu     *sync.Mutex
+	level  slog.Level
+	plain  bool
+	stdout io.Writer
+	stderr io.Writer
+	// status is the -progress bar, kept on the last line of stderr below
+	// the log lines.
+	status string
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var prefix string
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == iconKey {
+			prefix = a.Value.String()
+			return false
+		}
+		return true
+	})
+	if h.plain {
+		prefix = plainTag(r.Level, prefix)
+	}
+
+	line := r.Message
+	if prefix != "" {
+		line = prefix + " " + line
+	}
+
+	w := h.stdout
+	if r.Level >= slog.LevelWarn || r.Level < slog.LevelInfo {
+		w = h.stderr
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.status != "" {
+		fmt.Fprint(h.stderr, clearLine)
+	}
+	_, err := fmt.Fprintln(w, line)
+	if h.status != "" {
+		fmt.Fprint(h.stderr, h.status)
+	}
+	return err
+}
+
+// clearLine returns the cursor to the start of the line and erases it.
+const clearLine = "\r\x1b[K"
+
+// setStatus replaces the status line, or erases it when status is empty.
+func (h *textHandler) setStatus(status string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if status != "" || h.status != "" {
+		fmt.Fprint(h.stderr, clearLine+status)
+	}
+	h.status = status
+}
+
+// plainTag returns the ASCII tag that replaces a record's emoji icon.
+func plainTag(level slog.Level, emoji string) string {
+	switch {
+	case level >= slog.LevelError:
+		return "[ERROR]"
+	case level >= slog.LevelWarn:
+		return "[WARN]"
+	case level < slog.LevelInfo:
+		return "[DEBUG]"
+	case emoji == "✅":
+		return "[OK]"
+	default:
+		return "[INFO]"
+	}
+}
+
+func (h *textHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *textHandler) WithGroup(string) slog.Handler { return h }
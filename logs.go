@@ -0,0 +1,84 @@
+package railflush
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// maxLogLineLength caps each log line shown by -logs-on-failure, so a single
+// huge line (e.g. a minified stack trace) cannot flood the output.
+const maxLogLineLength = 500
+
+const queryDeploymentLogs = `
+query ($deploymentId: String!, $limit: Int) {
+  deploymentLogs(deploymentId: $deploymentId, limit: $limit) {
+    timestamp
+    message
+    severity
+  }
+}`
+
+// deploymentLogsData represents the response from the deployment logs query.
+type deploymentLogsData struct {
+	DeploymentLogs []LogLine `json:"deploymentLogs"`
+}
+
+// LogLine is a single line of a deployment's output.
+type LogLine struct {
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+	Severity  string    `json:"severity"`
+}
+
+// DeploymentLogs fetches up to limit of the most recent log lines of a
+// deployment, oldest first.
+func (c *Client) DeploymentLogs(ctx context.Context, deploymentID string, limit int) ([]LogLine, error) {
+	resp, err := c.do(ctx, queryDeploymentLogs, map[string]any{
+		"deploymentId": deploymentID,
+		"limit":        limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying deployment logs: %w", err)
+	}
+
+	var data deploymentLogsData
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, fmt.Errorf("parsing deployment logs: %w", err)
+	}
+	lines := data.DeploymentLogs
+	if len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	return lines, nil
+}
+
+// logFailedDeployment logs the last lines of a deployment that ended in a
+// failed status. Failing to fetch them is only a warning.
+func logFailedDeployment(ctx context.Context, c *Client, t target, deploymentID string, limit int) {
+	lines, err := c.DeploymentLogs(ctx, deploymentID, limit)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Could not fetch logs of deployment %s: %v", deploymentID, err), Icon("⚠️"),
+			"service_id", t.ServiceID, "deployment_id", deploymentID, "error", err)
+		return
+	}
+	if len(lines) == 0 {
+		slog.Warn(fmt.Sprintf("Deployment %s of service %s has no logs", deploymentID, t.label), Icon("📜"),
+			"service_id", t.ServiceID, "deployment_id", deploymentID)
+		return
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		msg := strings.TrimRight(line.Message, "\n")
+		if r := []rune(msg); len(r) > maxLogLineLength {
+			msg = string(r[:maxLogLineLength]) + "…"
+		}
+		fmt.Fprintf(&b, "\n    %s %s", line.Timestamp.Format(time.TimeOnly), msg)
+	}
+	slog.Warn(fmt.Sprintf("Last %d log line(s) of deployment %s of service %s:%s", len(lines), deploymentID, t.label, b.String()), Icon("📜"),
+		"service_id", t.ServiceID, "deployment_id", deploymentID)
+}
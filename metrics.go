@@ -0,0 +1,78 @@
+package railflush
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// pushgatewayJob is the job name metrics are grouped under in the Pushgateway.
+const pushgatewayJob = "railflush"
+
+// metricsText renders the metrics of cfg's project in the Prometheus text
+// exposition format, with per-environment service counts.
+func metricsText(report Summary, cfg Config) string {
+	counts := make(map[string]*Counts, len(cfg.EnvironmentIDs))
+	for _, envID := range cfg.EnvironmentIDs {
+		counts[envID] = &Counts{}
+	}
+	for _, r := range report.Services {
+		c, ok := counts[r.EnvironmentID]
+		if !ok || r.ProjectID != cfg.ProjectID {
+			continue
+		}
+		c.Add(r)
+	}
+
+	var b strings.Builder
+	gauge := func(name, help string, value func(c *Counts) int) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, envID := range cfg.EnvironmentIDs {
+			fmt.Fprintf(&b, "%s{environment_id=%q} %d\n", name, envID, value(counts[envID]))
+		}
+	}
+	gauge("railflush_services_total", "Services targeted by the last run.", (*Counts).Total)
+	gauge("railflush_services_succeeded", "Services successfully processed by the last run.", func(c *Counts) int { return c.Succeeded })
+	gauge("railflush_services_failed", "Services that failed in the last run.", func(c *Counts) int { return c.Failed })
+
+	fmt.Fprintf(&b, "# HELP railflush_run_duration_seconds Duration of the last run.\n# TYPE railflush_run_duration_seconds gauge\n")
+	fmt.Fprintf(&b, "railflush_run_duration_seconds %g\n", float64(report.ElapsedMS)/1000)
+	return b.String()
+}
+
+// pushMetrics replaces the run's metrics in a Prometheus Pushgateway, grouped
+// by job and project ID, with one push per project.
+func pushMetrics(ctx context.Context, client *http.Client, gatewayURL string, report Summary, cfg Config) error {
+	for _, g := range cfg.groups() {
+		if err := pushProjectMetrics(ctx, client, gatewayURL, report, cfg.forGroup(g)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushProjectMetrics pushes the metrics of cfg's single project.
+func pushProjectMetrics(ctx context.Context, client *http.Client, gatewayURL string, report Summary, cfg Config) error {
+	endpoint := strings.TrimRight(gatewayURL, "/") + "/metrics/job/" + url.PathEscape(pushgatewayJob) +
+		"/project_id/" + url.PathEscape(cfg.ProjectID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewBufferString(metricsText(report, cfg)))
+	if err != nil {
+		return fmt.Errorf("creating pushgateway request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushing metrics for project %s: %w", cfg.ProjectID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushing metrics for project %s: unexpected status %d", cfg.ProjectID, resp.StatusCode)
+	}
+	return nil
+}
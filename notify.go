@@ -0,0 +1,238 @@
+package railflush
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// discordDescriptionLimit is the maximum length of a Discord embed description.
+const discordDescriptionLimit = 4096
+
+// Embed colors used for Discord notifications.
+const (
+	discordColorSuccess = 0x2ecc71
+	discordColorFailure = 0xe74c3c
+)
+
+// runSummary holds the pieces of a run report that every notifier renders.
+type runSummary struct {
+	Action       string
+	Verb         string
+	Projects     []string
+	Environments []string
+	Succeeded    int
+	Failed       int
+	Skipped      int
+	ElapsedMS    int64
+	Failures     []ServiceResult
+	Report       Summary
+}
+
+// newRunSummary extracts what notifications need from report.
+func newRunSummary(report Summary, cfg Config) runSummary {
+	s := runSummary{
+		Action:    report.Action,
+		Verb:      cfg.Action.Past,
+		Succeeded: report.Succeeded,
+		Failed:    report.Failed,
+		Skipped:   report.Skipped,
+		ElapsedMS: report.ElapsedMS,
+		Report:    report,
+	}
+	for _, g := range cfg.groups() {
+		if !slices.Contains(s.Projects, g.ProjectID) {
+			s.Projects = append(s.Projects, g.ProjectID)
+		}
+		for _, envID := range g.EnvironmentIDs {
+			if !slices.Contains(s.Environments, envID) {
+				s.Environments = append(s.Environments, envID)
+			}
+		}
+	}
+	for _, r := range report.Services {
+		if r.Status == StatusFailed {
+			s.Failures = append(s.Failures, r)
+		}
+	}
+	return s
+}
+
+// where describes where a failed service lives, naming the project only when
+// the run covered several.
+func (s runSummary) where(r ServiceResult) string {
+	if len(s.Projects) > 1 {
+		return fmt.Sprintf("project `%s`, environment `%s`", r.ProjectID, r.EnvironmentID)
+	}
+	return fmt.Sprintf("environment `%s`", r.EnvironmentID)
+}
+
+// totals formats the succeeded/failed/skipped counts.
+func (s runSummary) totals() string {
+	t := fmt.Sprintf("✅ %d %s, ❌ %d failed", s.Succeeded, s.Verb, s.Failed)
+	if s.Skipped > 0 {
+		t += fmt.Sprintf(", ⏭️ %d skipped", s.Skipped)
+	}
+	if s.Report.Healthy > 0 {
+		t += fmt.Sprintf(", 💚 %d healthy", s.Report.Healthy)
+	}
+	if s.Report.Filtered > 0 {
+		t += fmt.Sprintf(", 🔎 %d filtered", s.Report.Filtered)
+	}
+	return t
+}
+
+// notifier delivers a run summary to an external service.
+type notifier interface {
+	// name identifies the target in log messages.
+	name() string
+	// payload builds the JSON body posted to the webhook.
+	payload(s runSummary) (any, error)
+	// webhook returns the URL the payload is posted to.
+	webhook() string
+}
+
+// notifiers returns a notifier for every webhook configured in cfg.
+func notifiers(cfg Config) []notifier {
+	var ns []notifier
+	add := func(kind []notifier) {
+		for i, n := range kind {
+			if len(kind) > 1 {
+				n = numberedNotifier{n, i + 1, len(kind)}
+			}
+			ns = append(ns, n)
+		}
+	}
+	var slack, discord, webhooks []notifier
+	for _, u := range cfg.SlackWebhooks {
+		slack = append(slack, slackNotifier{url: u})
+	}
+	for _, u := range cfg.DiscordWebhooks {
+		discord = append(discord, discordNotifier{url: u})
+	}
+	for _, u := range cfg.WebhookURLs {
+		webhooks = append(webhooks, webhookNotifier{url: u, tmpl: cfg.WebhookTemplate})
+	}
+	add(slack)
+	add(discord)
+	add(webhooks)
+	return ns
+}
+
+// numberedNotifier tells apart the webhooks of one kind in log messages, e.g.
+// "Slack 2/3", when several are configured.
+type numberedNotifier struct {
+	notifier
+	n, of int
+}
+
+func (n numberedNotifier) name() string { return fmt.Sprintf("%s %d/%d", n.notifier.name(), n.n, n.of) }
+
+// notify posts the run summary to n's webhook.
+func notify(ctx context.Context, client *http.Client, n notifier, report Summary, cfg Config) error {
+	payload, err := n.payload(newRunSummary(report, cfg))
+	if err != nil {
+		return fmt.Errorf("building %s message: %w", n.name(), err)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshaling %s message: %w", n.name(), err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhook(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating %s request: %w", n.name(), err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to %s: %w", n.name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("posting to %s: unexpected status %d", n.name(), resp.StatusCode)
+	}
+	return nil
+}
+
+// slackNotifier posts mrkdwn messages to a Slack incoming webhook.
+type slackNotifier struct {
+	url string
+}
+
+// slackMessage is the payload accepted by Slack incoming webhooks.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+func (slackNotifier) name() string      { return "Slack" }
+func (n slackNotifier) webhook() string { return n.url }
+
+func (slackNotifier) payload(s runSummary) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚂 *railflush* %s finished in %dms (project `%s`)\n", s.Action, s.ElapsedMS, strings.Join(s.Projects, "`, `"))
+	b.WriteString(s.totals())
+	for _, r := range s.Failures {
+		fmt.Fprintf(&b, "\n• `%s` (%s): %s", r.ServiceID, s.where(r), r.Error)
+	}
+	return slackMessage{Text: b.String()}, nil
+}
+
+// discordNotifier posts embeds to a Discord webhook.
+type discordNotifier struct {
+	url string
+}
+
+// discordMessage is the payload accepted by Discord webhooks.
+type discordMessage struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func (discordNotifier) name() string      { return "Discord" }
+func (n discordNotifier) webhook() string { return n.url }
+
+func (discordNotifier) payload(s runSummary) (any, error) {
+	embed := discordEmbed{
+		Title: fmt.Sprintf("🚂 railflush %s finished", s.Action),
+		Color: discordColorSuccess,
+		Fields: []discordField{
+			{Name: "Results", Value: s.totals()},
+			{Name: "Project", Value: "`" + strings.Join(s.Projects, "`, `") + "`", Inline: true},
+			{Name: "Environment", Value: "`" + strings.Join(s.Environments, "`, `") + "`", Inline: true},
+			{Name: "Elapsed", Value: fmt.Sprintf("%dms", s.ElapsedMS), Inline: true},
+		},
+	}
+	if s.Failed > 0 || s.Skipped > 0 {
+		embed.Color = discordColorFailure
+	}
+
+	var b strings.Builder
+	for _, r := range s.Failures {
+		line := fmt.Sprintf("• `%s` (%s): %s\n", r.ServiceID, s.where(r), r.Error)
+		if b.Len()+len(line) > discordDescriptionLimit {
+			break
+		}
+		b.WriteString(line)
+	}
+	embed.Description = strings.TrimSuffix(b.String(), "\n")
+	return discordMessage{Embeds: []discordEmbed{embed}}, nil
+}
@@ -0,0 +1,248 @@
+package railflush
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// Output formats selectable with -output.
+const (
+	OutputText   = "text"
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+	OutputTable  = "table"
+)
+
+// Per-service outcomes reported in results. Services that succeed report the
+// action's past tense ("restarted", "redeployed") or, in dry-run mode,
+// "would_<action>".
+const (
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+	// StatusHealthy marks a service left alone by -restart-if because its
+	// latest deployment is not degraded.
+	StatusHealthy = "healthy"
+	// StatusFiltered marks a service left alone because its deployment falls
+	// outside -since/-until.
+	StatusFiltered = "filtered"
+)
+
+// serviceResultFields lists the JSON field names of ServiceResult, the names
+// -json-fields accepts.
+func serviceResultFields() []string {
+	var fields []string
+	t := reflect.TypeFor[ServiceResult]()
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// ServiceResult is the outcome of processing a single service.
+type ServiceResult struct {
+	ServiceID     string `json:"service_id"`
+	ProjectID     string `json:"project_id"`
+	EnvironmentID string `json:"environment_id"`
+	DeploymentID  string `json:"deployment_id,omitempty"`
+	Action        string `json:"action"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	DurationMS    int64  `json:"duration_ms"`
+	// QueryMS, ActionMS and WaitMS split DurationMS into the time spent
+	// finding the deployment, triggering the action and, with -wait, waiting
+	// for it. Each is omitted when its step did not run.
+	QueryMS  int64 `json:"query_ms,omitempty"`
+	ActionMS int64 `json:"action_ms,omitempty"`
+	WaitMS   int64 `json:"wait_ms,omitempty"`
+
+	// label describes the service in log lines; it defaults to ServiceID.
+	label string
+	// err is the error behind Error, for errors.Is checks such as ErrAuth.
+	err error
+	// order is the service's place in configuration order, by which
+	// Results.Summary lists it.
+	order int
+}
+
+// Err returns the error the service failed with, or nil. It is not preserved
+// in JSON output.
+func (r ServiceResult) Err() error {
+	return r.err
+}
+
+// Succeeded reports whether the service was restarted (or, in dry-run mode,
+// would have been).
+func (r ServiceResult) Succeeded() bool {
+	return r.Status != StatusFailed && r.Status != StatusSkipped && r.Status != StatusHealthy && r.Status != StatusFiltered
+}
+
+// fail logs err for the service and marks the result as failed.
+func (r ServiceResult) fail(err error) ServiceResult {
+	label := r.label
+	if label == "" {
+		label = r.ServiceID
+	}
+	slog.Error(fmt.Sprintf("Service %s: %v", label, err), Icon("❌"),
+		"service_id", r.ServiceID, "project_id", r.ProjectID, "environment_id", r.EnvironmentID, "deployment_id", r.DeploymentID, "error", err)
+	r.Status = StatusFailed
+	r.Error = err.Error()
+	r.err = err
+	return r
+}
+
+// Summary summarizes a complete run. Its JSON keys are emitted in field order,
+// and Services lists services in configuration order: service names that could
+// not be resolved first, then each project, environment and service in turn.
+type Summary struct {
+	Version   string           `json:"version"`
+	Commit    string           `json:"commit"`
+	BuildDate string           `json:"build_date"`
+	Action    string           `json:"action"`
+	Services  []ServiceResult  `json:"services"`
+	Projects  []ProjectSummary `json:"projects"`
+	Counts
+	Aborted        string `json:"aborted,omitempty"`
+	AbortedSkipped int    `json:"aborted_skipped,omitempty"`
+	RetryBudget    int    `json:"retry_budget,omitempty"`
+	RetriesUsed    int    `json:"retries_used,omitempty"`
+	ElapsedMS      int64  `json:"elapsed_ms"`
+}
+
+// ProjectSummary tallies the results of a single project in a run.
+type ProjectSummary struct {
+	ProjectID string `json:"project_id"`
+	Counts
+}
+
+// Counts tallies service outcomes.
+type Counts struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+	Healthy   int `json:"healthy,omitempty"`
+	Filtered  int `json:"filtered,omitempty"`
+}
+
+// Add counts r's outcome.
+func (c *Counts) Add(r ServiceResult) {
+	switch {
+	case r.Succeeded():
+		c.Succeeded++
+	case r.Status == StatusSkipped:
+		c.Skipped++
+	case r.Status == StatusHealthy:
+		c.Healthy++
+	case r.Status == StatusFiltered:
+		c.Filtered++
+	default:
+		c.Failed++
+	}
+}
+
+// Total returns the number of services counted.
+func (c Counts) Total() int {
+	return c.Succeeded + c.Failed + c.Skipped + c.Healthy + c.Filtered
+}
+
+// SummaryLine formats the one-line outcome of a run, e.g. "Done: 3 restarted,
+// 0 failed (120ms)", as logged at the end of a run and printed by
+// -summary-only.
+func SummaryLine(report Summary, cfg Config) string {
+	verb := cfg.Action.Past
+	if cfg.DryRun {
+		verb = "would be " + verb
+	}
+	counts := fmt.Sprintf("%d %s, %d failed", report.Succeeded, verb, report.Failed)
+	if report.Skipped > 0 {
+		counts += fmt.Sprintf(", %d skipped", report.Skipped)
+	}
+	if report.AbortedSkipped > 0 {
+		counts += fmt.Sprintf(" (%d after aborting)", report.AbortedSkipped)
+	}
+	if report.Healthy > 0 {
+		counts += fmt.Sprintf(", %d healthy", report.Healthy)
+	}
+	if report.Filtered > 0 {
+		counts += fmt.Sprintf(", %d filtered", report.Filtered)
+	}
+	return fmt.Sprintf("Done: %s (%dms)", counts, report.ElapsedMS)
+}
+
+// logSummary logs the final one-line run summary, followed by each service's
+// deployment and outcome (left to the table with -output table), then a line
+// per project or, for a single project, per environment when there are
+// several.
+func logSummary(report Summary, cfg Config) {
+	verb := cfg.Action.Past
+	if cfg.DryRun {
+		verb = "would be " + verb
+	}
+	slog.Log(context.Background(), levelSummary, SummaryLine(report, cfg), Icon("🏁"), "succeeded", report.Succeeded, "failed", report.Failed, "skipped", report.Skipped,
+		"healthy", report.Healthy, "filtered", report.Filtered, "aborted_skipped", report.AbortedSkipped, "elapsed_ms", report.ElapsedMS)
+	if report.Aborted != "" {
+		slog.Log(context.Background(), levelSummary, "Run aborted: "+report.Aborted, Icon("🛑"), "aborted", report.Aborted)
+	}
+	if report.RetryBudget > 0 {
+		slog.Log(context.Background(), levelSummary, fmt.Sprintf("Retry budget: %d of %d retries used", report.RetriesUsed, report.RetryBudget), Icon("💸"),
+			"retries_used", report.RetriesUsed, "retry_budget", report.RetryBudget)
+	}
+	if cfg.Output != OutputTable {
+		for _, r := range report.Services {
+			logServiceSummary(r)
+		}
+	}
+
+	groups := cfg.groups()
+	if len(groups) > 1 {
+		for _, p := range report.Projects {
+			slog.Log(context.Background(), levelSummary, fmt.Sprintf("Project %s: %d %s, %d failed, %d skipped", p.ProjectID, p.Succeeded, verb, p.Failed, p.Skipped), Icon("📁"),
+				"project_id", p.ProjectID, "succeeded", p.Succeeded, "failed", p.Failed, "skipped", p.Skipped)
+		}
+		return
+	}
+	if len(groups) == 0 || len(groups[0].EnvironmentIDs) < 2 {
+		return
+	}
+	for _, envID := range groups[0].EnvironmentIDs {
+		var env Counts
+		for _, r := range report.Services {
+			if r.EnvironmentID == envID {
+				env.Add(r)
+			}
+		}
+		slog.Log(context.Background(), levelSummary, fmt.Sprintf("Environment %s: %d %s, %d failed, %d skipped", envID, env.Succeeded, verb, env.Failed, env.Skipped), Icon("🌐"),
+			"environment_id", envID, "succeeded", env.Succeeded, "failed", env.Failed, "skipped", env.Skipped)
+	}
+}
+
+// logServiceSummary logs r's line in the run summary, e.g. "Service a:
+// restarted, deployment d1", so the deployment to look at by hand is at hand
+// without scrolling back.
+func logServiceSummary(r ServiceResult) {
+	label := r.label
+	if label == "" {
+		label = r.ServiceID
+	}
+	deployment := "no deployment"
+	if r.DeploymentID != "" {
+		deployment = "deployment " + r.DeploymentID
+	}
+	icon := "✅"
+	switch r.Status {
+	case StatusFailed:
+		icon = "❌"
+	case StatusSkipped:
+		icon = "⏭️"
+	case StatusHealthy:
+		icon = "💚"
+	case StatusFiltered:
+		icon = "🔎"
+	}
+	slog.Log(context.Background(), levelSummary, fmt.Sprintf("Service %s: %s, %s", label, r.Status, deployment), Icon(icon),
+		"service_id", r.ServiceID, "environment_id", r.EnvironmentID, "deployment_id", r.DeploymentID, "status", r.Status)
+}
@@ -0,0 +1,111 @@
+package railflush
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+const queryMe = `
+query {
+  me {
+    id
+  }
+}`
+
+const queryProjectMembers = `
+query ($projectId: String!) {
+  projectMembers(projectId: $projectId) {
+    id
+    role
+  }
+}`
+
+// meData represents the response from the me query.
+type meData struct {
+	Me struct {
+		ID string `json:"id"`
+	} `json:"me"`
+}
+
+// projectMembersData represents the response from the project members query.
+type projectMembersData struct {
+	ProjectMembers []projectMember `json:"projectMembers"`
+}
+
+// projectMember is a user's membership of a project.
+type projectMember struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+}
+
+// restartRoles are the project roles allowed to restart and redeploy.
+var restartRoles = []string{"ADMIN", "MEMBER"}
+
+// checkPermissions works out, for -check-permissions, whether the token may
+// trigger deployments in each target's project, from the role its user holds
+// there. It returns each project's role or, when restarts would be refused,
+// the reason why. A token without a user, such as a team token, cannot be
+// checked; that is logged and nil maps are returned. Only a token rejected
+// outright is returned as an error.
+func checkPermissions(ctx context.Context, c *Client, targets []target) (roles map[string]string, denied map[string]error, err error) {
+	resp, err := c.do(ctx, queryMe, nil)
+	if tokenRejected(err) {
+		return nil, nil, err
+	}
+	var me meData
+	if err == nil {
+		err = json.Unmarshal(resp.Data, &me)
+	}
+	if err != nil || me.Me.ID == "" {
+		slog.Warn(fmt.Sprintf("Restart permissions not checked: the token's user could not be looked up (team tokens have none): %v", err), Icon("⚠️"), "error", err)
+		return nil, nil, nil
+	}
+
+	roles, denied = map[string]string{}, map[string]error{}
+	for _, t := range targets {
+		projectID := t.ProjectID
+		if _, ok := roles[projectID]; ok {
+			continue
+		}
+		if _, ok := denied[projectID]; ok {
+			continue
+		}
+		resp, err := c.do(ctx, queryProjectMembers, map[string]any{"projectId": projectID})
+		if tokenRejected(err) {
+			return nil, nil, err
+		}
+		var data projectMembersData
+		if err == nil {
+			err = json.Unmarshal(resp.Data, &data)
+		}
+		if err != nil {
+			denied[projectID] = fmt.Errorf("checking permissions in project %s: %w", projectID, err)
+			continue
+		}
+
+		i := slices.IndexFunc(data.ProjectMembers, func(m projectMember) bool { return m.ID == me.Me.ID })
+		switch {
+		case i < 0:
+			denied[projectID] = fmt.Errorf("the token's user is not a member of project %s, so restarts would be refused", projectID)
+		case !slices.Contains(restartRoles, data.ProjectMembers[i].Role):
+			denied[projectID] = fmt.Errorf("the token's role in project %s is %s, which cannot restart deployments", projectID, data.ProjectMembers[i].Role)
+		default:
+			roles[projectID] = data.ProjectMembers[i].Role
+			slog.Info(fmt.Sprintf("Token has role %s in project %s, which may restart deployments", roles[projectID], projectID), Icon("🔑"),
+				"project_id", projectID, "role", roles[projectID])
+		}
+	}
+	return roles, denied, nil
+}
+
+// tokenRejected reports whether err is the API refusing the token itself, as
+// opposed to the token lacking access to something.
+func tokenRejected(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.StatusCode == http.StatusUnauthorized
+}
@@ -0,0 +1,143 @@
+package railflush
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+const queryPreflight = `
+query ($projectId: String!) {
+  project(id: $projectId) {
+    environments {
+      edges {
+        node {
+          id
+        }
+      }
+    }
+    services {
+      edges {
+        node {
+          id
+          serviceInstances {
+            edges {
+              node {
+                environmentId
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// preflightData represents the response from the preflight query.
+type preflightData struct {
+	Project *struct {
+		Environments struct {
+			Edges []struct {
+				Node struct {
+					ID string `json:"id"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"environments"`
+		Services struct {
+			Edges []struct {
+				Node struct {
+					ID               string `json:"id"`
+					ServiceInstances struct {
+						Edges []struct {
+							Node struct {
+								EnvironmentID string `json:"environmentId"`
+							} `json:"node"`
+						} `json:"edges"`
+					} `json:"serviceInstances"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"services"`
+	} `json:"project"`
+}
+
+// preflight checks that every project exists, that its configured
+// environments belong to it, and that every target service has an instance in
+// its environment. All problems found are reported in the returned error.
+func preflight(ctx context.Context, c *Client, cfg Config, targets []target) error {
+	var problems []string
+	for _, g := range cfg.groups() {
+		var groupTargets []target
+		for _, t := range targets {
+			if t.ProjectID == g.ProjectID {
+				groupTargets = append(groupTargets, t)
+			}
+		}
+		found, err := checkProject(ctx, c, g, groupTargets)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, found...)
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	slog.Info(fmt.Sprintf("Preflight passed for %d service(s)", len(targets)), Icon("✅"), "services", len(targets))
+	return nil
+}
+
+// checkProject runs the preflight checks for a single project group and
+// returns the problems found.
+func checkProject(ctx context.Context, c *Client, g ProjectGroup, targets []target) ([]string, error) {
+	resp, err := c.do(ctx, queryPreflight, map[string]any{
+		"projectId": g.ProjectID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying project %s: %w", g.ProjectID, err)
+	}
+
+	var data preflightData
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, fmt.Errorf("parsing project: %w", err)
+	}
+	if data.Project == nil {
+		return []string{fmt.Sprintf("project %s not found", g.ProjectID)}, nil
+	}
+
+	var environments []string
+	for _, edge := range data.Project.Environments.Edges {
+		environments = append(environments, edge.Node.ID)
+	}
+	instances := map[string][]string{}
+	for _, edge := range data.Project.Services.Edges {
+		envs := []string{}
+		for _, inst := range edge.Node.ServiceInstances.Edges {
+			envs = append(envs, inst.Node.EnvironmentID)
+		}
+		instances[edge.Node.ID] = envs
+	}
+
+	var problems []string
+	for _, envID := range g.EnvironmentIDs {
+		if !slices.Contains(environments, envID) {
+			problems = append(problems, fmt.Sprintf("environment %s not found in project %s", envID, g.ProjectID))
+		}
+	}
+	for _, t := range targets {
+		envs, ok := instances[t.ServiceID]
+		switch {
+		case !ok:
+			problem := fmt.Sprintf("service %s not found in project %s", t.ServiceID, g.ProjectID)
+			if !slices.Contains(problems, problem) {
+				problems = append(problems, problem)
+			}
+		case slices.Contains(environments, t.EnvironmentID) && !slices.Contains(envs, t.EnvironmentID):
+			problems = append(problems, fmt.Sprintf("service %s is not deployed in environment %s", t.ServiceID, t.EnvironmentID))
+		}
+	}
+	return problems, nil
+}
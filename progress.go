@@ -0,0 +1,175 @@
+package railflush
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress modes selectable with -progress.
+const (
+	// ProgressAuto lets the command choose: a bar on a terminal and lines
+	// otherwise, or nothing with -quiet. Run treats it as ProgressLines.
+	ProgressAuto  = "auto"
+	ProgressBar   = "bar"
+	ProgressLines = "lines"
+	ProgressNone  = "none"
+)
+
+// progressInterval is how often ProgressLines logs a progress line while
+// services are still running, besides the line it logs as each one finishes.
+const progressInterval = 10 * time.Second
+
+// progressFrame is how often the progress bar is redrawn.
+const progressFrame = 125 * time.Millisecond
+
+// progress tracks which of a run's services are in flight; how many are done
+// comes from the run's results.
+type progress struct {
+	mu       sync.Mutex
+	total    int
+	results  *Results
+	inFlight []string
+	// finished, when set, is signaled without blocking as each service
+	// finishes.
+	finished chan struct{}
+}
+
+func (p *progress) start(label string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight = append(p.inFlight, label)
+}
+
+func (p *progress) finish(label string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i := slices.Index(p.inFlight, label); i >= 0 {
+		p.inFlight = slices.Delete(p.inFlight, i, i+1)
+	}
+	if p.finished != nil {
+		select {
+		case p.finished <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// describe formats the failures and services in flight, e.g. "1 failed,
+// waiting on a, b and 2 more".
+func (p *progress) describe(counts Counts) string {
+	var parts []string
+	if counts.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", counts.Failed))
+	}
+	if counts.Skipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", counts.Skipped))
+	}
+	if n := len(p.inFlight); n > 0 {
+		waiting := "waiting on " + strings.Join(p.inFlight[:min(n, 3)], ", ")
+		if n > 3 {
+			waiting += fmt.Sprintf(" and %d more", n-3)
+		}
+		parts = append(parts, waiting)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// line formats a progress line, e.g. "[3/20] 1 failed, waiting on a".
+func (p *progress) line() string {
+	counts := p.results.Counts()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	line := fmt.Sprintf("[%d/%d]", counts.Total(), p.total)
+	if d := p.describe(counts); d != "" {
+		line += " " + d
+	}
+	return line
+}
+
+// bar formats the progress bar with the given spinner frame.
+func (p *progress) bar(spinner string, plain bool) string {
+	counts := p.results.Counts()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	const width = 20
+	filled := 0
+	if p.total > 0 {
+		filled = counts.Total() * width / p.total
+	}
+	full, empty := "█", "░"
+	if plain {
+		full, empty = "#", "-"
+	}
+	bar := fmt.Sprintf("%s %s%s %d/%d", spinner, strings.Repeat(full, filled), strings.Repeat(empty, width-filled), counts.Total(), p.total)
+	if d := p.describe(counts); d != "" {
+		bar += " · " + d
+	}
+	return bar
+}
+
+// show reports p in cfg.Progress's mode until the returned function is
+// called. The bar is drawn below the log lines by the text logger, so it falls
+// back to lines when the default logger is not NewLogger's text logger.
+func (p *progress) show(cfg Config) (stop func()) {
+	mode := cfg.Progress
+	h, ok := slog.Default().Handler().(*textHandler)
+	if mode == ProgressBar && !ok {
+		mode = ProgressLines
+	}
+	if p.total == 0 || (mode != ProgressBar && mode != ProgressLines && mode != ProgressAuto) {
+		return func() {}
+	}
+
+	// Lines are logged as services finish, and every progressInterval in
+	// between so a long -wait does not look stuck. Services finishing in a
+	// burst share a line.
+	interval := progressInterval
+	var finished chan struct{}
+	if mode == ProgressBar {
+		interval = progressFrame
+	} else {
+		finished = make(chan struct{}, 1)
+		p.mu.Lock()
+		p.finished = finished
+		p.mu.Unlock()
+	}
+	spinner := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	if cfg.NoEmoji {
+		spinner = []string{"|", "/", "-", `\`}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			if mode == ProgressBar {
+				h.setStatus(p.bar(spinner[frame%len(spinner)], cfg.NoEmoji))
+			}
+			select {
+			case <-done:
+				if mode == ProgressBar {
+					h.setStatus("")
+				}
+				return
+			case <-ticker.C:
+			case <-finished:
+				ticker.Reset(interval)
+			}
+			if mode != ProgressBar {
+				slog.Info("Progress: "+p.line(), Icon("⏳"), "done", p.results.Counts().Total(), "total", p.total)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
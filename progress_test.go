@@ -0,0 +1,63 @@
+package railflush
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// messageLog collects the messages of the records it handles.
+type messageLog struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *messageLog) Enabled(context.Context, slog.Level) bool { return true }
+func (l *messageLog) WithAttrs([]slog.Attr) slog.Handler       { return l }
+func (l *messageLog) WithGroup(string) slog.Handler            { return l }
+
+func (l *messageLog) Handle(_ context.Context, r slog.Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, r.Message)
+	return nil
+}
+
+// waitFor waits up to a second for msg to be logged.
+func (l *messageLog) waitFor(t *testing.T, msg string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		l.mu.Lock()
+		found := slices.Contains(l.msgs, msg)
+		l.mu.Unlock()
+		if found {
+			return
+		}
+	}
+	t.Errorf("%q was not logged; got %q", msg, l.msgs)
+}
+
+func TestProgressLinesOnFinish(t *testing.T) {
+	log := &messageLog{}
+	slog.SetDefault(slog.New(log))
+	t.Cleanup(func() { slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil))) })
+
+	results := NewResults(DeploymentActions["restart"])
+	p := &progress{total: 2, results: results}
+	p.start("a")
+	p.start("b")
+	stop := p.show(Config{Progress: ProgressLines})
+	defer stop()
+
+	// Each finished service logs a line well before progressInterval.
+	results.Add(ServiceResult{ServiceID: "a", Status: StatusFailed})
+	p.finish("a")
+	log.waitFor(t, "Progress: [1/2] 1 failed, waiting on b")
+	results.Add(ServiceResult{ServiceID: "b", Status: "restarted"})
+	p.finish("b")
+	log.waitFor(t, "Progress: [2/2] 1 failed")
+}
@@ -0,0 +1,108 @@
+package railflush
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ProjectGroup bundles a project with the environments and services to
+// restart in it, so one run can cover several projects.
+type ProjectGroup struct {
+	ProjectID             string              `json:"project_id"`
+	EnvironmentIDs        []string            `json:"environment_ids"`
+	ServiceIDs            []string            `json:"service_ids,omitempty"`
+	ServiceNames          []string            `json:"service_names,omitempty"`
+	EnvironmentServiceIDs map[string][]string `json:"environment_service_ids,omitempty"`
+}
+
+// projectSpec is how a project group is written in -project and in the
+// config file, where a single environment_id is accepted as well.
+type projectSpec struct {
+	ProjectGroup
+	EnvironmentID string `json:"environment_id"`
+}
+
+// group normalizes s into a ProjectGroup.
+func (s projectSpec) group() ProjectGroup {
+	g := s.ProjectGroup
+	g.ProjectID = strings.TrimSpace(g.ProjectID)
+	if len(g.EnvironmentIDs) == 0 && s.EnvironmentID != "" {
+		g.EnvironmentIDs = []string{s.EnvironmentID}
+	}
+	g.EnvironmentServiceIDs = trimEnvironmentServices(g.EnvironmentServiceIDs)
+	g.EnvironmentIDs = withMappedEnvironments(trimIDs(g.EnvironmentIDs), g.EnvironmentServiceIDs)
+	g.ServiceIDs = trimIDs(g.ServiceIDs)
+	g.ServiceNames = trimIDs(g.ServiceNames)
+	return g
+}
+
+// problems lists the settings a project group is missing. Services are only
+// required when needServices is set, i.e. without -all.
+func (g ProjectGroup) problems(needServices bool) []string {
+	var problems []string
+	if g.ProjectID == "" {
+		problems = append(problems, "project_id is required")
+	}
+	if len(g.EnvironmentIDs) == 0 {
+		problems = append(problems, "environment_id or environment_ids is required")
+	}
+	if needServices && len(g.ServiceIDs) == 0 && len(g.ServiceNames) == 0 && len(g.EnvironmentServiceIDs) == 0 {
+		problems = append(problems, "service_ids, service_names or environment_service_ids is required")
+	}
+	return problems
+}
+
+// projectFlag is a repeatable flag.Value collecting project groups, each
+// given as a JSON object or an array of objects.
+type projectFlag []projectSpec
+
+func (p *projectFlag) String() string { return "" }
+
+func (p *projectFlag) Set(s string) error {
+	s = strings.TrimSpace(s)
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.DisallowUnknownFields()
+	if strings.HasPrefix(s, "[") {
+		var specs []projectSpec
+		if err := dec.Decode(&specs); err != nil {
+			return fmt.Errorf("parsing project list: %w", err)
+		}
+		*p = append(*p, specs...)
+		return nil
+	}
+	var spec projectSpec
+	if err := dec.Decode(&spec); err != nil {
+		return fmt.Errorf("parsing project: %w", err)
+	}
+	*p = append(*p, spec)
+	return nil
+}
+
+// groups returns every project group in the run: the top-level project when
+// it has services configured (or with -all), followed by the -project groups.
+func (cfg Config) groups() []ProjectGroup {
+	var groups []ProjectGroup
+	if cfg.ProjectID != "" && (cfg.All || len(cfg.ServiceIDs) > 0 || len(cfg.ServiceNames) > 0 || len(cfg.EnvironmentServiceIDs) > 0) {
+		groups = append(groups, ProjectGroup{
+			ProjectID:             cfg.ProjectID,
+			EnvironmentIDs:        cfg.EnvironmentIDs,
+			ServiceIDs:            cfg.ServiceIDs,
+			ServiceNames:          cfg.ServiceNames,
+			EnvironmentServiceIDs: cfg.EnvironmentServiceIDs,
+		})
+	}
+	return append(groups, cfg.Projects...)
+}
+
+// forGroup returns a copy of cfg scoped to a single project group.
+func (cfg Config) forGroup(g ProjectGroup) Config {
+	cfg.ProjectID = g.ProjectID
+	cfg.EnvironmentIDs = g.EnvironmentIDs
+	cfg.ServiceIDs = g.ServiceIDs
+	cfg.ServiceNames = g.ServiceNames
+	cfg.EnvironmentServiceIDs = g.EnvironmentServiceIDs
+	cfg.Projects = nil
+	return cfg
+}
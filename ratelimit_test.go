@@ -0,0 +1,83 @@
+package railflush
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterNil(t *testing.T) {
+	l := NewRateLimiter(0)
+	if l != nil {
+		t.Fatalf("NewRateLimiter(0) = %v, want nil", l)
+	}
+	for range 100 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatalf("nil limiter wait: %v", err)
+		}
+	}
+}
+
+func TestRateLimiterPacing(t *testing.T) {
+	const n, interval = 6, 50 * time.Millisecond
+	l := NewRateLimiter(float64(time.Second / interval))
+
+	start := time.Now()
+	var mu sync.Mutex
+	var done []time.Duration
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.wait(context.Background()); err != nil {
+				t.Errorf("wait: %v", err)
+			}
+			mu.Lock()
+			done = append(done, time.Since(start))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// The first token is free and each later one waits one interval more,
+	// however many callers share the limiter.
+	var last time.Duration
+	for _, d := range done {
+		last = max(last, d)
+	}
+	if least := (n - 1) * interval; last < least {
+		t.Errorf("%d waits took %s, want at least %s", n, last, least)
+	}
+	if limit := n * 2 * interval; last > limit {
+		t.Errorf("%d waits took %s, want at most %s", n, last, limit)
+	}
+}
+
+func TestRateLimiterRefundOnCancel(t *testing.T) {
+	const interval = 200 * time.Millisecond
+	l := NewRateLimiter(float64(time.Second / interval))
+
+	start := time.Now()
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("first wait: %v", err)
+	}
+
+	// This caller reserves the token due one interval from now, then gives up.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("canceled wait = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Its token was handed back, so the next caller gets it instead of
+	// waiting a second interval.
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("third wait: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < interval || elapsed >= 2*interval {
+		t.Errorf("third wait returned after %s, want between %s and %s", elapsed, interval, 2*interval)
+	}
+}
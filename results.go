@@ -0,0 +1,69 @@
+package railflush
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Results collects the outcomes of a run's services as they finish. It is
+// safe for concurrent use, so workers add to it directly.
+type Results struct {
+	mu       sync.Mutex
+	action   DeploymentAction
+	start    time.Time
+	services []ServiceResult
+	counts   Counts
+}
+
+// NewResults returns an empty Results for a run of action starting now.
+func NewResults(action DeploymentAction) *Results {
+	return &Results{action: action, start: time.Now()}
+}
+
+// Add records r's outcome.
+func (rs *Results) Add(r ServiceResult) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.services = append(rs.services, r)
+	rs.counts.Add(r)
+}
+
+// Counts returns the outcomes recorded so far.
+func (rs *Results) Counts() Counts {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.counts
+}
+
+// Summary tallies the outcomes recorded so far into a Summary, overall and
+// per project, with the time elapsed since NewResults. Services are listed in
+// the order they were planned in, whatever order they finished in.
+func (rs *Results) Summary() Summary {
+	rs.mu.Lock()
+	services := slices.Clone(rs.services)
+	rs.mu.Unlock()
+	slices.SortStableFunc(services, func(a, b ServiceResult) int { return a.order - b.order })
+
+	report := Summary{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		Action:    rs.action.Name,
+		Services:  services,
+		ElapsedMS: time.Since(rs.start).Milliseconds(),
+	}
+	for _, r := range services {
+		i := slices.IndexFunc(report.Projects, func(p ProjectSummary) bool { return p.ProjectID == r.ProjectID })
+		if i < 0 {
+			i = len(report.Projects)
+			report.Projects = append(report.Projects, ProjectSummary{ProjectID: r.ProjectID})
+		}
+		report.Add(r)
+		report.Projects[i].Add(r)
+		if r.Status == StatusSkipped && (r.Error == skipReasonFailFast || r.Error == skipReasonMaxFailures) {
+			report.AbortedSkipped++
+		}
+	}
+	return report
+}
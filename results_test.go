@@ -0,0 +1,55 @@
+package railflush
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestResultsConcurrent(t *testing.T) {
+	const n = 200
+	results := NewResults(DeploymentActions["restart"])
+
+	var wg sync.WaitGroup
+	for i := n - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := ServiceResult{ServiceID: fmt.Sprintf("svc-%d", i), ProjectID: fmt.Sprintf("p%d", i%2), Status: "restarted", order: i}
+			switch i % 4 {
+			case 1:
+				r.Status = StatusFailed
+			case 2:
+				r.Status, r.Error = StatusSkipped, skipReasonFailFast
+			}
+			results.Add(r)
+			// Read while others are still adding, as the progress bar does.
+			results.Counts()
+			results.Summary()
+		}()
+	}
+	wg.Wait()
+
+	summary := results.Summary()
+	want := Counts{Succeeded: n / 2, Failed: n / 4, Skipped: n / 4}
+	if summary.Counts != want {
+		t.Errorf("Counts = %+v, want %+v", summary.Counts, want)
+	}
+	if got := results.Counts(); got != want {
+		t.Errorf("Results.Counts() = %+v, want %+v", got, want)
+	}
+	if summary.AbortedSkipped != n/4 {
+		t.Errorf("AbortedSkipped = %d, want %d", summary.AbortedSkipped, n/4)
+	}
+	if len(summary.Services) != n {
+		t.Fatalf("got %d services, want %d", len(summary.Services), n)
+	}
+	for i, r := range summary.Services {
+		if want := fmt.Sprintf("svc-%d", i); r.ServiceID != want {
+			t.Fatalf("Services[%d] = %s, want %s: not in configuration order", i, r.ServiceID, want)
+		}
+	}
+	if len(summary.Projects) != 2 || summary.Projects[0].ProjectID != "p0" || summary.Projects[0].Total() != n/2 {
+		t.Errorf("Projects = %+v, want p0 and p1 with %d services each", summary.Projects, n/2)
+	}
+}
@@ -0,0 +1,185 @@
+package railflush
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default backoff parameters, used for those a RetryPolicy leaves zero.
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+	retryFactor    = 2
+)
+
+// RetryPolicy controls how API requests are paced and how transient failures
+// are retried.
+type RetryPolicy struct {
+	MaxRetries int
+	// ActionRetries caps retries of restart and redeploy mutations; see
+	// Client.mutate for why they are not retried as freely as queries.
+	ActionRetries int
+	// Limiter, when set, gates every request attempt, including retries.
+	Limiter *RateLimiter
+	// BaseDelay is the backoff before the first retry, growing by Factor
+	// for each further one up to MaxDelay. Zero values use 500ms, 10s and 2.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Factor    float64
+	// NoJitter waits the exact backoff instead of a random delay between
+	// half of it and all of it.
+	NoJitter bool
+
+	// budget, when set, caps the retries of a whole run; see Config.RetryBudget.
+	budget *retryBudget
+}
+
+// retryBudget counts the retries made across a run against a limit shared by
+// all workers. A nil *retryBudget allows any number of retries.
+type retryBudget struct {
+	limit     int64
+	used      atomic.Int64
+	exhausted sync.Once
+}
+
+// newRetryBudget returns a budget of limit retries, or nil when limit is zero.
+func newRetryBudget(limit int) *retryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &retryBudget{limit: int64(limit)}
+}
+
+// take reserves one retry, reporting false once the budget is spent.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	if b.used.Add(1) > b.limit {
+		b.used.Add(-1)
+		b.exhausted.Do(func() {
+			slog.Warn(fmt.Sprintf("Retry budget of %d exhausted; failing further errors without retrying", b.limit), Icon("💸"), "retry_budget", b.limit)
+		})
+		return false
+	}
+	return true
+}
+
+// ErrRateLimited is matched (with errors.Is) by errors caused by the API
+// answering 429 Too Many Requests, once retries are used up.
+var ErrRateLimited = errors.New("rate limited")
+
+// StatusError is returned when the API responds with a non-200 status. Body
+// is a bounded prefix of the response body.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
+
+// Is makes a 429 response match ErrRateLimited.
+func (e *StatusError) Is(target error) bool {
+	return target == ErrRateLimited && e.StatusCode == http.StatusTooManyRequests
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// backoff returns the delay to wait before the given retry attempt (starting
+// at 1), jittered unless p.NoJitter is set.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	maxDelay := cmp.Or(p.MaxDelay, retryMaxDelay)
+	d := maxDelay
+	if f := float64(cmp.Or(p.BaseDelay, retryBaseDelay)) * math.Pow(cmp.Or(p.Factor, retryFactor), float64(attempt-1)); f < float64(maxDelay) {
+		d = time.Duration(f)
+	}
+	if p.NoJitter {
+		return d
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// parseRetryAfter parses a Retry-After header given either as delay seconds or
+// as an HTTP date. It returns 0 when the header is absent or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// retryDelay returns how long to wait before retrying err, preferring the
+// server's Retry-After hint over exponential backoff. The hint is capped at
+// p.MaxDelay like the backoff, so one response cannot park a worker for hours.
+func (p RetryPolicy) retryDelay(err error, attempt int) time.Duration {
+	var se *StatusError
+	if errors.As(err, &se) && se.RetryAfter > 0 {
+		return min(se.RetryAfter, cmp.Or(p.MaxDelay, retryMaxDelay))
+	}
+	return p.backoff(attempt)
+}
+
+// isRetryable reports whether err is a transient failure: a network error, a
+// truncated response or a 429/5xx response. GraphQL errors, other 4xx responses
+// and malformed JSON are never retried.
+func isRetryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	var ue *url.Error
+	return errors.As(err, &ue) || errors.Is(err, errTruncatedResponse)
+}
+
+// withRetry calls fn until it succeeds, fails permanently, or the policy's
+// retries are exhausted. Waiting between attempts stops when ctx is done.
+// attrs are added to the retry log lines.
+func withRetry(ctx context.Context, policy RetryPolicy, fn func() error, attrs ...any) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > policy.MaxRetries || ctx.Err() != nil || !isRetryable(err) {
+			return err
+		}
+		if !policy.budget.take() {
+			return fmt.Errorf("%w (retry budget of %d exhausted)", err, policy.budget.limit)
+		}
+
+		delay := policy.retryDelay(err, attempt)
+		slog.Warn(fmt.Sprintf("Retry %d/%d in %s: %v", attempt, policy.MaxRetries, delay.Round(time.Millisecond), err),
+			append([]any{Icon("🔁"), "attempt", attempt, "max_retries", policy.MaxRetries, "delay", delay, "error", err}, attrs...)...)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
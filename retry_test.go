@@ -0,0 +1,136 @@
+package railflush
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"5", 5 * time.Second},
+		{"86400", 24 * time.Hour},
+		{"-3", 0},
+		{"1.5", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"Wed, 14 Oct 2026 25:00:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	retryAfter := func(d time.Duration) error {
+		return &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: d}
+	}
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		err     error
+		attempt int
+		want    time.Duration
+	}{
+		{"Retry-After", RetryPolicy{NoJitter: true}, retryAfter(3 * time.Second), 1, 3 * time.Second},
+		{"Retry-After capped at the default max", RetryPolicy{NoJitter: true}, retryAfter(24 * time.Hour), 1, retryMaxDelay},
+		{"Retry-After capped at MaxDelay", RetryPolicy{MaxDelay: 2 * time.Second}, retryAfter(time.Minute), 1, 2 * time.Second},
+		{"backoff without Retry-After", RetryPolicy{NoJitter: true}, &StatusError{StatusCode: 503}, 1, retryBaseDelay},
+		{"backoff grows by Factor", RetryPolicy{NoJitter: true, BaseDelay: 100 * time.Millisecond, Factor: 3}, errors.New("x"), 3, 900 * time.Millisecond},
+		{"backoff capped at MaxDelay", RetryPolicy{NoJitter: true, BaseDelay: time.Second, MaxDelay: 5 * time.Second}, errors.New("x"), 10, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.retryDelay(tt.err, tt.attempt); got != tt.want {
+				t.Errorf("retryDelay = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second}
+	for range 100 {
+		// Jitter keeps the delay within the upper half of the backoff.
+		if d := p.backoff(1); d < 500*time.Millisecond || d > time.Second {
+			t.Fatalf("backoff(1) = %s, want between 500ms and 1s", d)
+		}
+	}
+}
+
+// failFirst answers the first n deployments queries with status and body and
+// leaves the rest to the fake.
+func failFirst(n int32, status int, body string) func(fakeCall) (int, string) {
+	var seen atomic.Int32
+	return func(call fakeCall) (int, string) {
+		if strings.Contains(call.Query, "deployments(") && seen.Add(1) <= n {
+			return status, body
+		}
+		return 0, ""
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		override  func(fakeCall) (int, string)
+		wantErr   bool
+		wantCalls int
+	}{
+		{"5xx then success", failFirst(2, http.StatusServiceUnavailable, "unavailable"), false, 3},
+		{"429 then success", failFirst(1, http.StatusTooManyRequests, "slow down"), false, 2},
+		{"gives up after MaxRetries", failFirst(100, http.StatusBadGateway, "bad gateway"), true, 4},
+		{"4xx is not retried", failFirst(100, http.StatusBadRequest, "bad request"), true, 1},
+		{"GraphQL error is not retried", failFirst(100, http.StatusOK, `{"errors":[{"message":"Problem processing request"}]}`), true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t, successDeployments("a"))
+			api.override = tt.override
+			c := NewClient(api.Client(), api.URL, "token", RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, NoJitter: true})
+
+			deps, err := c.Deployments(context.Background(), "p", "e", "a", defaultStatuses)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Deployments = %v, want an error", deps)
+				}
+			} else if err != nil || len(deps) != 1 {
+				t.Errorf("Deployments = %v, %v, want one deployment", deps, err)
+			}
+			if n := api.count("deployments("); n != tt.wantCalls {
+				t.Errorf("got %d requests, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMutationRetries(t *testing.T) {
+	api := newFakeAPI(t, nil)
+	api.override = func(call fakeCall) (int, string) {
+		if strings.Contains(call.Query, "deploymentRestart") {
+			return http.StatusBadGateway, "bad gateway"
+		}
+		return 0, ""
+	}
+	// Mutations are retried ActionRetries times, not MaxRetries.
+	c := NewClient(api.Client(), api.URL, "token", RetryPolicy{MaxRetries: 5, ActionRetries: 1, BaseDelay: time.Millisecond, NoJitter: true})
+	if err := c.Restart(context.Background(), "dep-a"); err == nil {
+		t.Error("Restart succeeded, want an error")
+	}
+	if n := api.count("deploymentRestart"); n != 2 {
+		t.Errorf("got %d restart requests, want 2", n)
+	}
+}
@@ -0,0 +1,834 @@
+// Package railflush restarts or redeploys the latest deployments of Railway
+// services. The railflush command in cmd/railflush is a thin wrapper around Run.
+package railflush
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// emptyRetryDelay is the pause between -retry-empty attempts.
+const emptyRetryDelay = 2 * time.Second
+
+// Errors recorded for services skipped or canceled because the run was
+// aborted by -fail-fast or -max-failures.
+const (
+	skipReasonFailFast    = "fail-fast"
+	skipReasonMaxFailures = "max-failures"
+)
+
+// errAborted wraps the cancellation cause once too many services have failed.
+var errAborted = errors.New("run aborted")
+
+// target is a single service to process in a single environment.
+type target struct {
+	ServiceID     string
+	ProjectID     string
+	EnvironmentID string
+	label         string
+	// prefetched is set once deployments holds t's deployments, fetched
+	// in a batch with other targets.
+	prefetched  bool
+	deployments []Deployment
+	// role is the token's role in t's project and denied why it could not
+	// restart t, as found by -check-permissions.
+	role   string
+	denied error
+}
+
+// PlannedService is a service a run is about to act on, as passed to
+// Config.Confirm.
+type PlannedService struct {
+	ProjectID     string
+	EnvironmentID string
+	ServiceID     string
+}
+
+// buildTargets expands each project group's environments and services into
+// targets, resolving service names separately for each environment. Names that
+// cannot be resolved are returned as failed results so other environments
+// still run. With cfg.All every service of each environment is targeted, and
+// the error is set when they cannot be listed.
+func buildTargets(ctx context.Context, c *Client, cfg Config) ([]target, []ServiceResult, error) {
+	var targets []target
+	var failed []ServiceResult
+	groups := cfg.groups()
+	// seen catches a service configured twice for the same environment, e.g.
+	// through a repeated environment ID or project group.
+	seen := map[[3]string]bool{}
+	var cache serviceCache
+	if !cfg.NoServiceCache {
+		cache = serviceCache{}
+	}
+	for _, g := range groups {
+		for _, envID := range g.EnvironmentIDs {
+			label := func(id string) string {
+				switch {
+				case len(groups) > 1 && len(g.EnvironmentIDs) > 1:
+					return fmt.Sprintf("%s in project %s, environment %s", id, g.ProjectID, envID)
+				case len(groups) > 1:
+					return fmt.Sprintf("%s in project %s", id, g.ProjectID)
+				case len(g.EnvironmentIDs) > 1:
+					return fmt.Sprintf("%s in environment %s", id, envID)
+				}
+				return id
+			}
+			add := func(id string) {
+				key := [3]string{g.ProjectID, envID, id}
+				if seen[key] {
+					slog.Warn(fmt.Sprintf("Service %s is configured more than once; restarting it once", label(id)), Icon("⚠️"),
+						"service_id", id, "project_id", g.ProjectID, "environment_id", envID)
+					return
+				}
+				seen[key] = true
+				targets = append(targets, target{ServiceID: id, ProjectID: g.ProjectID, EnvironmentID: envID, label: label(id)})
+			}
+
+			if cfg.All {
+				services, err := cache.services(ctx, c, g.ProjectID, envID)
+				if err != nil {
+					return nil, nil, fmt.Errorf("listing services in environment %s: %w", envID, err)
+				}
+				for _, svc := range services {
+					add(svc.ID)
+				}
+				continue
+			}
+
+			ids := slices.Clone(g.ServiceIDs)
+			for _, id := range g.EnvironmentServiceIDs[envID] {
+				if !slices.Contains(ids, id) {
+					ids = append(ids, id)
+				}
+			}
+			if len(g.ServiceNames) > 0 {
+				resolutions, err := resolveServiceNames(ctx, c, cache, g.ProjectID, envID, g.ServiceNames)
+				for i, name := range g.ServiceNames {
+					result := ServiceResult{ServiceID: name, ProjectID: g.ProjectID, EnvironmentID: envID, Action: cfg.Action.Name, label: label(name)}
+					switch {
+					case err != nil:
+						failed = append(failed, result.fail(err))
+					case resolutions[i].Err != nil:
+						failed = append(failed, result.fail(resolutions[i].Err))
+					case !slices.Contains(ids, resolutions[i].ID):
+						ids = append(ids, resolutions[i].ID)
+					}
+				}
+			}
+
+			for _, id := range ids {
+				add(id)
+			}
+		}
+	}
+	return targets, failed, nil
+}
+
+// filterTargets applies -only and -skip to targets. When both are given -only
+// takes precedence. IDs in either filter that match no target are warned about.
+func filterTargets(targets []target, cfg Config) []target {
+	if len(cfg.Only) == 0 && len(cfg.Skip) == 0 {
+		return targets
+	}
+
+	filter, keep := cfg.Skip, false
+	if len(cfg.Only) > 0 {
+		filter, keep = cfg.Only, true
+		if len(cfg.Skip) > 0 {
+			slog.Warn("Both -only and -skip given; ignoring -skip", Icon("⚠️"))
+		}
+	}
+	for _, id := range filter {
+		if !slices.ContainsFunc(targets, func(t target) bool { return t.ServiceID == id }) {
+			slog.Warn(fmt.Sprintf("Service %s is not in the configured services; ignoring it", id), Icon("⚠️"), "service_id", id)
+		}
+	}
+
+	var filtered []target
+	for _, t := range targets {
+		if slices.Contains(filter, t.ServiceID) == keep {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+// newestTarget picks, for -newest-only, the target whose latest deployment was
+// created most recently, logging which one and why. Deployments are looked up
+// in batches where possible and kept on the targets, so the chosen one is not
+// looked up again. Targets without a deployment are passed over; the error is
+// set when a lookup fails or none has a deployment.
+func newestTarget(ctx context.Context, c *Client, cfg Config, targets []target) (target, error) {
+	if !cfg.NoBatch {
+		prefetchDeployments(ctx, c, cfg, targets)
+	}
+	newest, runnerUp := -1, -1
+	var latest []Deployment
+	for i := range targets {
+		t := &targets[i]
+		if !t.prefetched {
+			deployments, err := c.Deployments(ctx, t.ProjectID, t.EnvironmentID, t.ServiceID, cfg.Statuses)
+			if err != nil {
+				return target{}, fmt.Errorf("looking up deployments of service %s: %w", t.label, err)
+			}
+			t.prefetched, t.deployments = true, deployments
+		}
+		dep, _ := latestOf(t.deployments, cfg.Statuses)
+		latest = append(latest, dep)
+		if dep.ID == "" {
+			continue
+		}
+		switch {
+		case newest < 0 || dep.CreatedAt.After(latest[newest].CreatedAt):
+			newest, runnerUp = i, newest
+		case runnerUp < 0 || dep.CreatedAt.After(latest[runnerUp].CreatedAt):
+			runnerUp = i
+		}
+	}
+	if newest < 0 {
+		return target{}, fmt.Errorf("%w (status %s) for any of the %d service(s)", ErrNoDeployment, strings.Join(cfg.Statuses, "/"), len(targets))
+	}
+
+	t, dep := targets[newest], latest[newest]
+	reason := fmt.Sprintf("its latest deployment %s, created %s, is the newest", dep.ID, dep.CreatedAt.Format(time.RFC3339))
+	switch {
+	case runnerUp >= 0 && latest[runnerUp].CreatedAt.Equal(dep.CreatedAt):
+		reason += fmt.Sprintf(", tied with %s's %s; the first configured is picked", targets[runnerUp].label, latest[runnerUp].ID)
+	case runnerUp >= 0:
+		reason += fmt.Sprintf(" (next is %s's %s, created %s)", targets[runnerUp].label, latest[runnerUp].ID, latest[runnerUp].CreatedAt.Format(time.RFC3339))
+	}
+	slog.Info(fmt.Sprintf("Selected service %s out of %d: %s", t.label, len(targets), reason), Icon("🆕"),
+		"service_id", t.ServiceID, "environment_id", t.EnvironmentID, "deployment_id", dep.ID, "created_at", dep.CreatedAt, "services", len(targets))
+	return t, nil
+}
+
+// findDeployment looks up the deployment to act on for t: the one given with
+// -deployment-id, the latest one or, with cfg.Rollback, the one before it.
+// When none is found it retries up to cfg.RetryEmpty more times, since a
+// just-finished deploy can briefly be missing from the deployments list.
+func findDeployment(ctx context.Context, c *Client, cfg Config, t target) (Deployment, error) {
+	if id, ok := cfg.DeploymentIDs[t.ServiceID]; ok {
+		slog.Info(fmt.Sprintf("Using deployment %s for service %s as given by -deployment-id", id, t.label), Icon("📌"),
+			"service_id", t.ServiceID, "environment_id", t.EnvironmentID, "deployment_id", id)
+		return Deployment{ID: id}, nil
+	}
+	for attempt := 1; ; attempt++ {
+		var dep Deployment
+		var err error
+		// Retries after an empty result always query again.
+		prefetched := t.prefetched && attempt == 1
+		if cfg.Rollback {
+			var current Deployment
+			if prefetched {
+				dep, current, err = previousOf(t.deployments, cfg.Statuses)
+			} else {
+				dep, current, err = c.PreviousDeployment(ctx, t.ProjectID, t.EnvironmentID, t.ServiceID, cfg.Statuses)
+			}
+			if err == nil {
+				slog.Info(fmt.Sprintf("Rolling back service %s from deployment %s to %s (created %s)", t.label, current.ID, dep.ID, dep.CreatedAt.Format(time.RFC3339)), Icon("⏪"),
+					"service_id", t.ServiceID, "environment_id", t.EnvironmentID, "deployment_id", dep.ID, "current_deployment_id", current.ID)
+			}
+		} else if prefetched {
+			dep, err = latestOf(t.deployments, cfg.Statuses)
+		} else {
+			dep, err = c.LatestDeployment(ctx, t.ProjectID, t.EnvironmentID, t.ServiceID, cfg.Statuses)
+		}
+		if !errors.Is(err, ErrNoDeployment) || attempt > cfg.RetryEmpty {
+			return dep, err
+		}
+
+		slog.Warn(fmt.Sprintf("No deployment found for service %s yet, retrying (%d/%d)", t.label, attempt, cfg.RetryEmpty), Icon("🔁"),
+			"service_id", t.ServiceID, "environment_id", t.EnvironmentID, "attempt", attempt)
+		timer := time.NewTimer(emptyRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return dep, err
+		case <-timer.C:
+		}
+	}
+}
+
+// prefetchDeployments looks up the deployments of targets in batches, one per
+// project and environment, so each service is spared its own query. Targets
+// given with -deployment-id or already looked up are left out. When a batch fails its targets are
+// left as they are and look up their deployments one by one instead.
+func prefetchDeployments(ctx context.Context, c *Client, cfg Config, targets []target) {
+	type scope struct{ projectID, environmentID string }
+	var scopes []scope
+	batches := map[scope][]int{}
+	for i, t := range targets {
+		if _, ok := cfg.DeploymentIDs[t.ServiceID]; ok || t.prefetched {
+			continue
+		}
+		s := scope{t.ProjectID, t.EnvironmentID}
+		if _, ok := batches[s]; !ok {
+			scopes = append(scopes, s)
+		}
+		batches[s] = append(batches[s], i)
+	}
+
+	fetched, requests := 0, 0
+	for _, s := range scopes {
+		indexes := batches[s]
+		serviceIDs := make([]string, len(indexes))
+		for j, i := range indexes {
+			serviceIDs[j] = targets[i].ServiceID
+		}
+		byService, n, err := c.DeploymentsBatch(ctx, s.projectID, s.environmentID, serviceIDs, cfg.Statuses)
+		requests += n
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Batched deployment lookup in environment %s failed, looking up its services one by one: %v", s.environmentID, err), Icon("⚠️"),
+				"project_id", s.projectID, "environment_id", s.environmentID, "error", err)
+			continue
+		}
+		for _, i := range indexes {
+			targets[i].prefetched, targets[i].deployments = true, byService[targets[i].ServiceID]
+		}
+		fetched += len(indexes)
+	}
+	if fetched > 0 {
+		slog.Info(fmt.Sprintf("Fetched deployments for %d service(s) in %d request(s)", fetched, requests), Icon("📦"),
+			"services", fetched, "requests", requests)
+	}
+}
+
+// restartService restarts (or redeploys, per cfg.Action) the latest active
+// deployment of a single service. ctx is not canceled by an interrupt, so a
+// restart already under way is carried out; interrupt is the caller's context,
+// whose cancellation cuts -wait short.
+func restartService(ctx, interrupt context.Context, c *Client, cfg Config, t target) (result ServiceResult) {
+	start := time.Now()
+	ctx, span := startSpan(ctx, "service "+cfg.Action.Name, spanKindInternal,
+		stringAttr("railway.service.id", t.ServiceID), stringAttr("railway.project.id", t.ProjectID),
+		stringAttr("railway.environment.id", t.EnvironmentID))
+	defer func() {
+		result.DurationMS = time.Since(start).Milliseconds()
+		var err error
+		if result.Status == StatusFailed {
+			err = errors.New(result.Error)
+		}
+		if result.DeploymentID != "" {
+			span.setAttr("railway.deployment.id", result.DeploymentID)
+		}
+		span.end(err)
+	}()
+
+	if d, ok := cfg.ServiceTimeouts[t.ServiceID]; ok {
+		ctx = withRequestTimeout(ctx, d)
+	}
+
+	result = ServiceResult{ServiceID: t.ServiceID, ProjectID: t.ProjectID, EnvironmentID: t.EnvironmentID, Action: cfg.Action.Name, label: t.label}
+	attrs := []any{"service_id", t.ServiceID, "project_id", t.ProjectID, "environment_id", t.EnvironmentID}
+
+	which := "latest"
+	if cfg.Rollback {
+		which = "previous"
+	}
+	if _, ok := cfg.DeploymentIDs[t.ServiceID]; !ok && !t.prefetched {
+		slog.Info(fmt.Sprintf("Fetching %s deployment for service %s", which, t.label), append(attrs, Icon("🔍"))...)
+	}
+
+	step := time.Now()
+	dep, err := findDeployment(ctx, c, cfg, t)
+	if _, ok := cfg.DeploymentIDs[t.ServiceID]; !ok {
+		result.QueryMS = time.Since(step).Milliseconds()
+	}
+	if errors.Is(err, ErrNoDeployment) && cfg.DetectInProgress {
+		if current, ok, checkErr := c.InProgressDeployment(ctx, t.ProjectID, t.EnvironmentID, t.ServiceID); checkErr == nil && ok {
+			err = &DeploymentInProgressError{DeploymentID: current.ID, Status: current.Status}
+			slog.Warn(fmt.Sprintf("Service %s: %v", t.label, err), append(attrs, Icon("🚧"), "deployment_id", current.ID, "deployment_status", current.Status)...)
+			result.DeploymentID, result.Status, result.Error, result.err = current.ID, StatusSkipped, err.Error(), err
+			return result
+		}
+	}
+	if err != nil {
+		return result.fail(err)
+	}
+	deploymentID := dep.ID
+	result.DeploymentID = deploymentID
+	attrs = append(attrs, "deployment_id", deploymentID, "action", cfg.Action.Name)
+
+	if cfg.RequireHealthy {
+		current, err := c.CurrentDeployment(ctx, t.ProjectID, t.EnvironmentID, t.ServiceID)
+		if err != nil {
+			return result.fail(fmt.Errorf("checking the current deployment: %w", err))
+		}
+		if !slices.Contains(cfg.HealthyStatuses, current.Status) {
+			return result.fail(&DeploymentUnhealthyError{DeploymentID: current.ID, Status: current.Status})
+		}
+	}
+	if len(cfg.RestartIf) > 0 && dep.Status != "" && !slices.Contains(cfg.RestartIf, dep.Status) {
+		result.Status, result.Error = StatusHealthy, fmt.Sprintf("deployment %s is %s, not one of -restart-if %s", deploymentID, dep.Status, strings.Join(cfg.RestartIf, ","))
+		slog.Info(fmt.Sprintf("Service %s is healthy: %s; leaving it alone", t.label, result.Error), append(attrs, Icon("💚"), "deployment_status", dep.Status)...)
+		return result
+	}
+	if reason := outsideWindow(dep, cfg); reason != "" {
+		result.Status, result.Error = StatusFiltered, reason
+		slog.Info(fmt.Sprintf("Filtering out service %s: %s", t.label, reason), append(attrs, Icon("🔎"), "created_at", dep.CreatedAt)...)
+		return result
+	}
+	if age := time.Since(dep.CreatedAt); cfg.MaxAge > 0 && !dep.CreatedAt.IsZero() && age > cfg.MaxAge {
+		result.Status = StatusSkipped
+		result.Error = fmt.Sprintf("deployment %s is %s old, older than -max-age %s; redeploy it instead", deploymentID, age.Round(time.Minute), cfg.MaxAge)
+		slog.Warn(fmt.Sprintf("Skipping service %s: %s", t.label, result.Error), append(attrs, Icon("🕰️"), "created_at", dep.CreatedAt)...)
+		return result
+	}
+
+	if cfg.DryRun {
+		if t.denied != nil {
+			return result.fail(t.denied)
+		}
+		msg := fmt.Sprintf("Would %s deployment %s for service %s", cfg.Action.Name, deploymentID, t.label)
+		if t.role != "" {
+			msg += fmt.Sprintf(" (permitted as %s)", t.role)
+		}
+		slog.Info(msg, append(attrs, Icon("🧪"))...)
+		result.Status = "would_" + cfg.Action.Name
+		return result
+	}
+
+	if cfg.PreRestartCmd != "" {
+		slog.Info(fmt.Sprintf("Running -pre-restart-cmd for service %s", t.label), append(attrs, Icon("🪝"))...)
+		if err := runHook(ctx, cfg.PreRestartCmd, cfg, t, result); err != nil {
+			return result.fail(fmt.Errorf("-pre-restart-cmd failed, not %s: %w", strings.ToLower(cfg.Action.Present), err))
+		}
+	}
+	if cfg.PostRestartCmd != "" {
+		// Runs once the outcome is known, whether or not the action worked,
+		// e.g. to undo what the pre-command did. That includes a service cut
+		// short by -fail-fast, -max-failures or -deadline, so it gets a
+		// context of its own.
+		defer func() {
+			slog.Info(fmt.Sprintf("Running -post-restart-cmd for service %s", t.label), append(attrs, Icon("🪝"))...)
+			hookCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), postHookTimeout)
+			defer cancel()
+			if err := runHook(hookCtx, cfg.PostRestartCmd, cfg, t, result); err != nil {
+				slog.Warn(fmt.Sprintf("-post-restart-cmd failed for service %s: %v", t.label, err), append(attrs, Icon("⚠️"), "error", err)...)
+			}
+		}()
+	}
+
+	// A restart keeps the deployment, so -wait compares it against how it was
+	// beforehand to tell that the restart really happened.
+	var before Deployment
+	if cfg.Wait && cfg.Action.Name == "restart" {
+		if before, err = c.deploymentState(ctx, deploymentID); err != nil {
+			slog.Warn(fmt.Sprintf("Could not record deployment %s before restarting it, so -wait cannot tell whether the restart happened: %v", deploymentID, err), append(attrs, Icon("⚠️"), "error", err)...)
+			before = Deployment{}
+		}
+	}
+
+	slog.Info(fmt.Sprintf("%s deployment %s for service %s", cfg.Action.Present, deploymentID, t.label), append(attrs, Icon("🔄"))...)
+
+	step = time.Now()
+	err = c.Trigger(ctx, cfg.Action, deploymentID)
+	result.ActionMS = time.Since(step).Milliseconds()
+	if err != nil {
+		return result.fail(err)
+	}
+
+	if cfg.Wait {
+		slog.Info(fmt.Sprintf("Waiting for deployment %s of service %s to become healthy", deploymentID, t.label), append(attrs, Icon("⏳"))...)
+		step = time.Now()
+		waitCtx, cancelWait := context.WithCancelCause(ctx)
+		stopInterrupt := context.AfterFunc(interrupt, func() { cancelWait(errInterrupted) })
+		err := waitForHealthy(waitCtx, c, deploymentID, cfg.HealthyStatuses, cfg.WaitTimeout, before)
+		stopInterrupt()
+		cancelWait(nil)
+		result.WaitMS = time.Since(step).Milliseconds()
+		if err != nil {
+			result = result.fail(err)
+			var failed *DeploymentFailedError
+			if cfg.LogsOnFailure > 0 && errors.As(err, &failed) {
+				logFailedDeployment(ctx, c, t, failed.DeploymentID, cfg.LogsOnFailure)
+			}
+			return result
+		}
+	}
+
+	slog.Info(fmt.Sprintf("Service %s %s successfully", t.label, cfg.Action.Past), append(attrs, Icon("✅"))...)
+	result.Status = cfg.Action.Past
+	return result
+}
+
+// outsideWindow explains why dep falls outside -since/-until, or returns ""
+// when it does not. Deployments without a known creation time (given with
+// -deployment-id) are never filtered.
+func outsideWindow(dep Deployment, cfg Config) string {
+	switch {
+	case dep.CreatedAt.IsZero():
+	case !cfg.Since.IsZero() && dep.CreatedAt.Before(cfg.Since):
+		return fmt.Sprintf("deployment %s was created at %s, before -since %s", dep.ID, dep.CreatedAt.Format(time.RFC3339), cfg.Since.Format(time.RFC3339))
+	case !cfg.Until.IsZero() && dep.CreatedAt.After(cfg.Until):
+		return fmt.Sprintf("deployment %s was created at %s, after -until %s", dep.ID, dep.CreatedAt.Format(time.RFC3339), cfg.Until.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// spreadOffsets returns n random start offsets within window, in ascending
+// order, or nil when window is zero.
+func spreadOffsets(n int, window time.Duration) []time.Duration {
+	if window <= 0 {
+		return nil
+	}
+	offsets := make([]time.Duration, n)
+	for i := range offsets {
+		offsets[i] = rand.N(window)
+	}
+	slices.Sort(offsets)
+	return offsets
+}
+
+// newAPIClient returns a Client for the API cfg configures.
+func newAPIClient(httpClient *http.Client, cfg Config) *Client {
+	api := NewClient(httpClient, cfg.APIURL, cfg.APIToken, cfg.Retry)
+	if cfg.UserAgent != "" {
+		api.SetUserAgent(cfg.UserAgent)
+	}
+	if cfg.DeploymentsLimit > 0 {
+		api.SetDeploymentsLimit(cfg.DeploymentsLimit)
+	}
+	api.SetRequireHTTP2(cfg.HTTP2)
+	api.SetTokenType(cfg.TokenType)
+	return api
+}
+
+// Run restarts (or redeploys, per cfg.Action) the configured services and
+// returns a summary of the outcome. Canceling ctx stops further services from
+// being started; services already in flight run to completion, bounded only by
+// cfg.Deadline. The error is set only when the run could not start, e.g. when
+// -preflight finds a problem; per-service failures are reported in the Summary.
+//
+// A Config built by hand rather than by LoadConfig gets LoadConfig's defaults
+// for a zero Concurrency, Action and Statuses: one service at a time,
+// restarting SUCCESS deployments.
+func Run(ctx context.Context, cfg Config) (Summary, error) {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Action.Name == "" {
+		cfg.Action = DeploymentActions["restart"]
+	}
+	if len(cfg.Statuses) == 0 {
+		cfg.Statuses = defaultStatuses
+	}
+	results := NewResults(cfg.Action)
+	groups := cfg.groups()
+	if len(groups) == 0 {
+		return Summary{}, &ConfigError{Problems: []string{"no project with services configured"}}
+	}
+
+	runCtx := context.WithoutCancel(ctx)
+	if cfg.Deadline > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, cfg.Deadline)
+		defer cancel()
+	}
+
+	if cfg.Wait && cfg.Deadline > 0 && cfg.WaitTimeout > cfg.Deadline {
+		slog.Warn(fmt.Sprintf("-wait-timeout %s is longer than -deadline %s; the deadline will cut waits short", cfg.WaitTimeout, cfg.Deadline), Icon("⚠️"),
+			"wait_timeout", cfg.WaitTimeout.String(), "deadline", cfg.Deadline.String())
+	}
+
+	httpClient := newHTTPClient(cfg)
+	// The budget is per run, so each -interval run starts with a full one.
+	budget := newRetryBudget(cfg.RetryBudget)
+	cfg.Retry.budget = budget
+	apiHTTP := newAPIHTTPClient(cfg)
+	if len(cfg.ServiceTimeouts) > 0 {
+		// Each request is then timed out through its context instead, so a
+		// service's own timeout can be longer than -timeout.
+		perRequest := *apiHTTP
+		perRequest.Timeout = 0
+		apiHTTP = &perRequest
+		runCtx = withRequestTimeout(runCtx, cfg.Timeout)
+	}
+	api := newAPIClient(apiHTTP, cfg)
+
+	var tr *tracer
+	if cfg.OTLPEndpoint != "" {
+		tr = newTracer(cfg.OTLPEndpoint, cfg.OTLPHeaders)
+		runCtx = context.WithValue(runCtx, tracerKey{}, tr)
+	}
+	var projectIDs, environmentIDs []string
+	for _, g := range groups {
+		projectIDs = append(projectIDs, g.ProjectID)
+		environmentIDs = append(environmentIDs, g.EnvironmentIDs...)
+	}
+	runCtx, runSpan := startSpan(runCtx, "railflush "+cfg.Action.Name, spanKindInternal,
+		stringAttr("railway.project.id", strings.Join(projectIDs, ",")), stringAttr("railway.environment.ids", strings.Join(environmentIDs, ",")))
+	// Spans are exported even after an interrupt or an exceeded deadline.
+	finishTrace := func(err error) {
+		runSpan.end(err)
+		if tr == nil {
+			return
+		}
+		if err := tr.export(context.WithoutCancel(runCtx), httpClient); err != nil {
+			slog.Warn(fmt.Sprintf("Trace export failed: %v", err), Icon("⚠️"), "error", err)
+		}
+	}
+
+	targets, unresolved, err := buildTargets(runCtx, api, cfg)
+	if err != nil {
+		finishTrace(err)
+		return Summary{}, err
+	}
+	targets = filterTargets(targets, cfg)
+	for _, id := range slices.Sorted(maps.Keys(cfg.DeploymentIDs)) {
+		if !slices.ContainsFunc(targets, func(t target) bool { return t.ServiceID == id }) {
+			slog.Warn(fmt.Sprintf("-deployment-id names service %s, which is not being restarted; ignoring it", id), Icon("⚠️"), "service_id", id)
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(cfg.ServiceTimeouts)) {
+		if !slices.ContainsFunc(targets, func(t target) bool { return t.ServiceID == id }) {
+			slog.Warn(fmt.Sprintf("service_timeouts names service %s, which is not being restarted; ignoring it", id), Icon("⚠️"), "service_id", id)
+		}
+	}
+
+	if len(groups) > 1 {
+		slog.Info(fmt.Sprintf("Targeting %d service(s) across %d projects", len(targets), len(groups)), Icon("📋"),
+			"services", len(targets), "project_ids", projectIDs)
+	} else {
+		msg := fmt.Sprintf("Targeting %d service(s) in project %s", len(targets), projectIDs[0])
+		if len(environmentIDs) > 1 {
+			msg += fmt.Sprintf(" across %d environments", len(environmentIDs))
+		}
+		slog.Info(msg, Icon("📋"), "services", len(targets), "project_id", projectIDs[0], "environment_ids", environmentIDs)
+	}
+
+	if cfg.Preflight {
+		if err := preflight(runCtx, api, cfg, targets); err != nil {
+			err = fmt.Errorf("preflight failed: %w", err)
+			finishTrace(err)
+			return Summary{}, err
+		}
+	}
+
+	if cfg.CheckPermissions && len(targets) > 0 {
+		roles, denied, err := checkPermissions(runCtx, api, targets)
+		if err != nil {
+			err = fmt.Errorf("checking permissions: %w", err)
+			finishTrace(err)
+			return Summary{}, err
+		}
+		for i, t := range targets {
+			targets[i].role, targets[i].denied = roles[t.ProjectID], denied[t.ProjectID]
+		}
+	}
+
+	if cfg.NewestOnly && len(targets) > 0 {
+		t, err := newestTarget(runCtx, api, cfg, targets)
+		if err != nil {
+			err = fmt.Errorf("picking the newest service: %w", err)
+			finishTrace(err)
+			return Summary{}, err
+		}
+		targets = []target{t}
+	}
+
+	if cfg.Confirm != nil && !cfg.DryRun && len(targets) > 0 {
+		plan := make([]PlannedService, len(targets))
+		for i, t := range targets {
+			plan[i] = PlannedService{ProjectID: t.ProjectID, EnvironmentID: t.EnvironmentID, ServiceID: t.ServiceID}
+		}
+		if err := cfg.Confirm(ctx, plan); err != nil {
+			finishTrace(err)
+			return Summary{}, err
+		}
+	}
+
+	// Staggered starts, and -wait with more services than -concurrency, can
+	// leave a service waiting long enough for a deploy to supersede a
+	// deployment looked up now, so each is then looked up just before acting.
+	staggered := cfg.Delay > 0 || cfg.Spread > 0 || (cfg.Wait && len(targets) > cfg.Concurrency)
+	if !cfg.NoBatch && !staggered {
+		prefetchDeployments(runCtx, api, cfg, targets)
+	}
+
+	// Workers record straight into results, which does its own locking.
+	// cfg.OnResult is called under a lock of its own.
+	var onResultMu sync.Mutex
+	prog := &progress{total: len(unresolved) + len(targets), results: results}
+	record := func(i int, r ServiceResult) {
+		r.order = i
+		results.Add(r)
+		label := r.label
+		if label == "" {
+			label = r.ServiceID
+		}
+		prog.finish(label)
+		if cfg.OnResult != nil {
+			onResultMu.Lock()
+			defer onResultMu.Unlock()
+			cfg.OnResult(r)
+		}
+	}
+	offset := len(unresolved)
+	for i, r := range unresolved {
+		record(i, r)
+	}
+
+	// Once -max-failures services have failed (one, with -fail-fast), the
+	// run is aborted and services still in flight are canceled.
+	limit, limitFlag, skipReason := cfg.MaxFailures, fmt.Sprintf("-max-failures %d", cfg.MaxFailures), skipReasonMaxFailures
+	if cfg.FailFast {
+		limit, limitFlag, skipReason = 1, "-fail-fast", skipReasonFailFast
+	}
+	workCtx, cancelWork := context.WithCancelCause(runCtx)
+	defer cancelWork(nil)
+	var failures atomic.Int64
+	var abortReason string // written once, before workCtx is canceled
+	recordFailure := func() {
+		if n := failures.Add(1); limit > 0 && n == int64(limit) {
+			abortReason = fmt.Sprintf("%d service(s) failed (%s)", n, limitFlag)
+			slog.Warn("Aborting the run: "+abortReason, Icon("🛑"), "failed", n)
+			cancelWork(fmt.Errorf("%w: %s", errAborted, abortReason))
+		}
+	}
+	for _, r := range unresolved {
+		if r.Status == StatusFailed {
+			recordFailure()
+		}
+	}
+	aborted := func() bool { return errors.Is(context.Cause(workCtx), errAborted) }
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for range min(cfg.Concurrency, len(targets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if aborted() {
+					record(offset+i, ServiceResult{ServiceID: targets[i].ServiceID, ProjectID: targets[i].ProjectID, EnvironmentID: targets[i].EnvironmentID,
+						Action: cfg.Action.Name, Status: StatusSkipped, Error: skipReason})
+					continue
+				}
+				prog.start(targets[i].label)
+				result := restartService(workCtx, ctx, api, cfg, targets[i])
+				if limit > 0 && result.Status == StatusFailed {
+					if aborted() {
+						result.Status, result.Error = StatusSkipped, skipReason
+					} else {
+						recordFailure()
+					}
+				}
+				record(offset+i, result)
+			}
+		}()
+	}
+
+	// Dispatch stops when ctx is canceled, the deadline passes or the run is
+	// aborted.
+	stopCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(workCtx, cancel)()
+
+	skipRemaining := func(i int) {
+		reason := "deadline exceeded"
+		switch {
+		case aborted():
+			reason = skipReason
+		case runCtx.Err() == nil:
+			reason = "interrupted"
+		}
+		slog.Warn(fmt.Sprintf("Skipping %d remaining service(s): %s", len(targets)-i, reason), Icon("⏰"),
+			"skipped", len(targets)-i, "reason", reason)
+		for j := i; j < len(targets); j++ {
+			record(offset+j, ServiceResult{
+				ServiceID:     targets[j].ServiceID,
+				ProjectID:     targets[j].ProjectID,
+				EnvironmentID: targets[j].EnvironmentID,
+				Action:        cfg.Action.Name,
+				Status:        StatusSkipped,
+				Error:         reason,
+			})
+		}
+	}
+
+	offsets := spreadOffsets(len(targets), cfg.Spread)
+	if offsets != nil {
+		slog.Info(fmt.Sprintf("Spreading %d service start(s) over %s", len(targets), cfg.Spread), Icon("🎲"), "spread", cfg.Spread.String())
+	}
+	dispatchStart := time.Now()
+	stopProgress := prog.show(cfg)
+
+dispatch:
+	for i := range targets {
+		var wait time.Duration
+		if i > 0 {
+			wait = cfg.Delay
+		}
+		if offsets != nil {
+			wait = max(wait, time.Until(dispatchStart.Add(offsets[i])))
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-stopCtx.Done():
+				timer.Stop()
+				skipRemaining(i)
+				break dispatch
+			case <-timer.C:
+			}
+		}
+		select {
+		case <-stopCtx.Done():
+			skipRemaining(i)
+			break dispatch
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	stopProgress()
+
+	summary := results.Summary()
+	summary.Aborted = abortReason
+	if budget != nil {
+		summary.RetryBudget, summary.RetriesUsed = cfg.RetryBudget, int(budget.used.Load())
+	}
+	if !cfg.logsDiscarded() {
+		logSummary(summary, cfg)
+	}
+
+	// Notifications still go out after an interrupt or an exceeded deadline;
+	// the HTTP client's timeout bounds them instead.
+	// Each webhook is posted to in turn; one failing does not stop the rest.
+	notifyCtx := context.WithoutCancel(runCtx)
+	if ns := notifiers(cfg); len(ns) > 0 {
+		sent := 0
+		for _, n := range ns {
+			if err := notify(notifyCtx, httpClient, n, summary, cfg); err != nil {
+				slog.Warn(fmt.Sprintf("%s notification failed: %v", n.name(), err), Icon("⚠️"), "error", err)
+				continue
+			}
+			sent++
+		}
+		slog.Debug(fmt.Sprintf("Sent %d of %d notification(s)", sent, len(ns)), Icon("📣"), "sent", sent, "total", len(ns))
+	}
+	if cfg.PushgatewayURL != "" {
+		if err := pushMetrics(notifyCtx, httpClient, cfg.PushgatewayURL, summary, cfg); err != nil {
+			slog.Warn(fmt.Sprintf("Metrics push failed: %v", err), Icon("⚠️"), "error", err)
+		}
+	}
+
+	var runErr error
+	if summary.Failed > 0 {
+		runErr = fmt.Errorf("%d service(s) failed", summary.Failed)
+	}
+	finishTrace(runErr)
+
+	return summary, nil
+}
@@ -0,0 +1,161 @@
+package railflush
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runWithin runs Run, failing the test if it has not returned within d.
+func runWithin(t *testing.T, d time.Duration, ctx context.Context, cfg Config) (Summary, error) {
+	t.Helper()
+	type result struct {
+		summary Summary
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		s, err := Run(ctx, cfg)
+		done <- result{s, err}
+	}()
+	select {
+	case r := <-done:
+		return r.summary, r.err
+	case <-time.After(d):
+		t.Fatalf("Run did not return within %s", d)
+		return Summary{}, nil
+	}
+}
+
+func TestRunZeroValueConfig(t *testing.T) {
+	api := newFakeAPI(t, successDeployments("a", "b"))
+	// Concurrency, Action and Statuses are left at their zero values.
+	summary, err := runWithin(t, 5*time.Second, context.Background(), api.config("a", "b"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if summary.Succeeded != 2 || summary.Failed != 0 {
+		t.Errorf("Run = %d succeeded, %d failed, want 2 and 0: %+v", summary.Succeeded, summary.Failed, summary.Services)
+	}
+	if summary.Action != "restart" {
+		t.Errorf("Action = %q, want restart", summary.Action)
+	}
+	if n := api.count("deploymentRestart"); n != 2 {
+		t.Errorf("got %d restarts, want 2", n)
+	}
+}
+
+// slowFailures makes restarting dep-bad fail after a short delay and holds
+// every other restart for long, so the other services are still restarting
+// when dep-bad's failure aborts the run.
+func slowFailures(call fakeCall) (int, string) {
+	if !strings.Contains(call.Query, "deploymentRestart") {
+		return 0, ""
+	}
+	if call.Variables["id"] == "dep-bad" {
+		time.Sleep(100 * time.Millisecond)
+		return http.StatusBadGateway, "bad gateway"
+	}
+	time.Sleep(time.Second)
+	return 0, ""
+}
+
+func TestRunPostHookAfterFailFast(t *testing.T) {
+	api := newFakeAPI(t, successDeployments("a", "bad"))
+	api.override = slowFailures
+	log := filepath.Join(t.TempDir(), "hook.log")
+
+	cfg := api.config("a", "bad")
+	cfg.Concurrency = 2
+	cfg.FailFast = true
+	cfg.PostRestartCmd = `echo "$RAILFLUSH_SERVICE_ID $RAILFLUSH_STATUS" >> ` + log
+	if _, err := runWithin(t, 5*time.Second, context.Background(), cfg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	out, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("reading hook log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	slices.Sort(lines)
+	// a was canceled mid-restart by -fail-fast; its hook still ran.
+	if want := []string{"a failed", "bad failed"}; !slices.Equal(lines, want) {
+		t.Errorf("post-restart-cmd ran for %q, want %q", lines, want)
+	}
+}
+
+// failRestarts makes restarting the deployments of the given services fail
+// with a non-retryable 400.
+func failRestarts(serviceIDs ...string) func(fakeCall) (int, string) {
+	return func(call fakeCall) (int, string) {
+		if strings.Contains(call.Query, "deploymentRestart") && slices.ContainsFunc(serviceIDs, func(id string) bool { return call.Variables["id"] == "dep-"+id }) {
+			return http.StatusBadRequest, "bad request"
+		}
+		return 0, ""
+	}
+}
+
+func TestRunAbort(t *testing.T) {
+	tests := []struct {
+		name        string
+		failFast    bool
+		maxFailures int
+		want        map[string]string
+		wantErrors  map[string]string // the skipped services' Error
+		wantAborted string
+	}{
+		{
+			name:        "fail-fast",
+			failFast:    true,
+			want:        map[string]string{"bad1": StatusFailed, "bad2": StatusSkipped, "c": StatusSkipped, "d": StatusSkipped},
+			wantErrors:  map[string]string{"bad2": skipReasonFailFast, "c": skipReasonFailFast, "d": skipReasonFailFast},
+			wantAborted: "1 service(s) failed (-fail-fast)",
+		},
+		{
+			name:        "max-failures",
+			maxFailures: 2,
+			want:        map[string]string{"bad1": StatusFailed, "bad2": StatusFailed, "c": StatusSkipped, "d": StatusSkipped},
+			wantErrors:  map[string]string{"c": skipReasonMaxFailures, "d": skipReasonMaxFailures},
+			wantAborted: "2 service(s) failed (-max-failures 2)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []string{"bad1", "bad2", "c", "d"}
+			api := newFakeAPI(t, successDeployments(ids...))
+			api.override = failRestarts("bad1", "bad2")
+			cfg := api.config(ids...)
+			cfg.FailFast, cfg.MaxFailures = tt.failFast, tt.maxFailures
+
+			summary, err := runWithin(t, 5*time.Second, context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := statusesByService(summary); !maps.Equal(got, tt.want) {
+				t.Errorf("statuses = %v, want %v", got, tt.want)
+			}
+			for _, r := range summary.Services {
+				if want, ok := tt.wantErrors[r.ServiceID]; ok && r.Error != want {
+					t.Errorf("%s: Error = %q, want %q", r.ServiceID, r.Error, want)
+				}
+			}
+			if summary.Aborted != tt.wantAborted {
+				t.Errorf("Aborted = %q, want %q", summary.Aborted, tt.wantAborted)
+			}
+			if want := len(tt.wantErrors); summary.AbortedSkipped != want {
+				t.Errorf("AbortedSkipped = %d, want %d", summary.AbortedSkipped, want)
+			}
+			// Services skipped after the abort are never restarted.
+			if n, want := api.count("deploymentRestart"), len(ids)-len(tt.wantErrors); n != want {
+				t.Errorf("got %d restarts, want %d", n, want)
+			}
+		})
+	}
+}
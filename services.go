@@ -0,0 +1,175 @@
+package railflush
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+const queryProjectServices = `
+query ($projectId: String!) {
+  project(id: $projectId) {
+    services {
+      edges {
+        node {
+          id
+          name
+          serviceInstances {
+            edges {
+              node {
+                environmentId
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// projectServicesData represents the response from the project services query.
+type projectServicesData struct {
+	Project struct {
+		Services struct {
+			Edges []struct {
+				Node struct {
+					ID               string `json:"id"`
+					Name             string `json:"name"`
+					ServiceInstances struct {
+						Edges []struct {
+							Node struct {
+								EnvironmentID string `json:"environmentId"`
+							} `json:"node"`
+						} `json:"edges"`
+					} `json:"serviceInstances"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"services"`
+	} `json:"project"`
+}
+
+// Service is a Railway service deployed in an environment.
+type Service struct {
+	ID   string
+	Name string
+}
+
+// projectService is a service together with the environments it has an
+// instance in.
+type projectService struct {
+	Service
+	EnvironmentIDs []string
+}
+
+// ProjectServices lists the services of a project that have an instance in the
+// given environment.
+func (c *Client) ProjectServices(ctx context.Context, projectID, environmentID string) ([]Service, error) {
+	all, err := c.listProjectServices(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return servicesIn(all, environmentID), nil
+}
+
+// listProjectServices lists every service of a project in any environment.
+func (c *Client) listProjectServices(ctx context.Context, projectID string) ([]projectService, error) {
+	resp, err := c.do(ctx, queryProjectServices, map[string]any{
+		"projectId": projectID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying project services: %w", err)
+	}
+
+	var data projectServicesData
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, fmt.Errorf("parsing project services: %w", err)
+	}
+
+	var services []projectService
+	for _, edge := range data.Project.Services.Edges {
+		svc := projectService{Service: Service{ID: edge.Node.ID, Name: edge.Node.Name}}
+		for _, inst := range edge.Node.ServiceInstances.Edges {
+			svc.EnvironmentIDs = append(svc.EnvironmentIDs, inst.Node.EnvironmentID)
+		}
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
+// servicesIn returns the services that have an instance in environmentID.
+func servicesIn(all []projectService, environmentID string) []Service {
+	var services []Service
+	for _, svc := range all {
+		if slices.Contains(svc.EnvironmentIDs, environmentID) {
+			services = append(services, svc.Service)
+		}
+	}
+	return services
+}
+
+// serviceCache remembers each project's services for the rest of a run, so
+// resolving names in several environments of a project queries it once. The
+// services query covers every environment, so entries are keyed by project. A
+// nil serviceCache (-no-service-cache) queries the API every time.
+type serviceCache map[string][]projectService
+
+// services lists the services of a project that have an instance in the given
+// environment, from the cache when possible.
+func (sc serviceCache) services(ctx context.Context, c *Client, projectID, environmentID string) ([]Service, error) {
+	all, ok := sc[projectID]
+	if ok {
+		slog.Debug(fmt.Sprintf("Using cached services of project %s", projectID), "project_id", projectID)
+	} else {
+		var err error
+		if all, err = c.listProjectServices(ctx, projectID); err != nil {
+			return nil, err
+		}
+		if sc != nil {
+			sc[projectID] = all
+		}
+	}
+	return servicesIn(all, environmentID), nil
+}
+
+// nameResolution is the outcome of resolving a single service name.
+type nameResolution struct {
+	Name string
+	ID   string
+	Err  error
+}
+
+// resolveServiceNames maps service names to IDs within an environment. Names
+// are matched case-insensitively; unmatched or ambiguous names carry an Err.
+// The returned error is set only when the services could not be listed.
+func resolveServiceNames(ctx context.Context, c *Client, cache serviceCache, projectID, environmentID string, names []string) ([]nameResolution, error) {
+	services, err := cache.services(ctx, c, projectID, environmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	resolutions := make([]nameResolution, len(names))
+	for i, name := range names {
+		var matches []string
+		for _, svc := range services {
+			if strings.EqualFold(svc.Name, name) {
+				matches = append(matches, svc.ID)
+			}
+		}
+
+		resolutions[i].Name = name
+		switch len(matches) {
+		case 0:
+			resolutions[i].Err = fmt.Errorf("no service named %q in environment %s", name, environmentID)
+		case 1:
+			resolutions[i].ID = matches[0]
+			slog.Info(fmt.Sprintf("Resolved service %q to %s in environment %s", name, matches[0], environmentID), Icon("🔎"),
+				"service_name", name, "service_id", matches[0], "environment_id", environmentID)
+		default:
+			resolutions[i].Err = fmt.Errorf("service name %q is ambiguous (matches %s)", name, strings.Join(matches, ", "))
+		}
+	}
+	return resolutions, nil
+}
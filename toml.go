@@ -0,0 +1,369 @@
+package railflush
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tomlParser is a recursive-descent parser over a TOML document.
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+	// defined records the tables opened with a [header], which TOML does not
+	// allow to be opened twice.
+	defined map[string]bool
+}
+
+// parseTOML parses the TOML subset used by config files: key/value pairs with
+// bare, quoted and dotted keys, [tables], [[arrays of tables]], basic and
+// literal strings, integers, floats, booleans, arrays and inline tables. The
+// result is built from map[string]any, []any and scalar values so it can be
+// re-encoded as JSON.
+func parseTOML(data []byte) (map[string]any, error) {
+	p := &tomlParser{src: string(data), line: 1, defined: map[string]bool{}}
+	root := map[string]any{}
+	current := root
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseHeader(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipBlank(false)
+		if !p.eof() && p.peek() != '\n' {
+			return nil, p.errorf("expected a newline after the value")
+		}
+	}
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *tomlParser) peek() byte { return p.src[p.pos] }
+
+// skipBlank skips spaces, tabs and comments and, when newlines is set, line
+// breaks as well.
+func (p *tomlParser) skipBlank(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+			p.line++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// parseHeader parses a [table] or [[array of tables]] header and returns the
+// table that following keys belong to.
+func (p *tomlParser) parseHeader(root map[string]any) (map[string]any, error) {
+	array := strings.HasPrefix(p.src[p.pos:], "[[")
+	p.pos++
+	if array {
+		p.pos++
+	}
+	p.skipBlank(false)
+	path, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, p.errorf("expected %q after table name", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.descend(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	name := strings.Join(path, ".")
+	last := path[len(path)-1]
+	if array {
+		existing, ok := parent[last]
+		tables, isArray := existing.([]any)
+		if ok && !isArray {
+			return nil, p.errorf("%s is already defined and is not an array of tables", name)
+		}
+		// Sub-tables of the previous element may be opened again.
+		for defined := range p.defined {
+			if strings.HasPrefix(defined, name+".") {
+				delete(p.defined, defined)
+			}
+		}
+		table := map[string]any{}
+		parent[last] = append(tables, table)
+		return table, nil
+	}
+
+	if p.defined[name] {
+		return nil, p.errorf("table %s is defined twice", name)
+	}
+	p.defined[name] = true
+	switch existing := parent[last].(type) {
+	case nil:
+		table := map[string]any{}
+		parent[last] = table
+		return table, nil
+	case map[string]any:
+		return existing, nil
+	}
+	return nil, p.errorf("%s is already defined and is not a table", name)
+}
+
+// descend walks path from m, creating missing tables. An array of tables
+// stands for its last element.
+func (p *tomlParser) descend(m map[string]any, path []string) (map[string]any, error) {
+	for i, key := range path {
+		switch v := m[key].(type) {
+		case nil:
+			next := map[string]any{}
+			m[key] = next
+			m = next
+		case map[string]any:
+			m = v
+		case []any:
+			last, ok := v[len(v)-1].(map[string]any)
+			if !ok {
+				return nil, p.errorf("%s is not a table", strings.Join(path[:i+1], "."))
+			}
+			m = last
+		default:
+			return nil, p.errorf("%s is not a table", strings.Join(path[:i+1], "."))
+		}
+	}
+	return m, nil
+}
+
+// parseKeyValue parses "key = value" into m.
+func (p *tomlParser) parseKeyValue(m map[string]any) error {
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.eof() || p.peek() != '=' {
+		return p.errorf("expected \"key = value\"")
+	}
+	p.pos++
+	p.skipBlank(false)
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := p.descend(m, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1]
+	if _, dup := parent[last]; dup {
+		return p.errorf("duplicate key %q", strings.Join(path, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// parseKey parses a bare, quoted or dotted key into its parts, leaving the
+// position after any trailing spaces.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var path []string
+	for {
+		p.skipBlank(false)
+		if p.eof() {
+			return nil, p.errorf("expected a key")
+		}
+		var part string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		case c == '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		default:
+			start := p.pos
+			for !p.eof() && isTOMLBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected a key")
+			}
+			part = p.src[start:p.pos]
+		}
+		path = append(path, part)
+
+		p.skipBlank(false)
+		if p.eof() || p.peek() != '.' {
+			return path, nil
+		}
+		p.pos++
+	}
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue parses a string, number, boolean, array or inline table.
+func (p *tomlParser) parseValue() (any, error) {
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+	switch p.peek() {
+	case '"':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			return nil, p.errorf("multi-line strings are not supported")
+		}
+		return p.parseBasicString()
+	case '\'':
+		if strings.HasPrefix(p.src[p.pos:], "'''") {
+			return nil, p.errorf("multi-line strings are not supported")
+		}
+		return p.parseLiteralString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n,]}#", p.peek()) < 0 {
+		p.pos++
+	}
+	token := p.src[start:p.pos]
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	number := strings.TrimPrefix(strings.ReplaceAll(token, "_", ""), "+")
+	if jsonNumber.MatchString(number) {
+		return json.Number(number), nil
+	}
+	return nil, p.errorf("unsupported value %q", token)
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	start := p.pos
+	for p.pos++; !p.eof(); p.pos++ {
+		switch p.peek() {
+		case '\\':
+			p.pos++
+		case '\n':
+			return "", p.errorf("unterminated string")
+		case '"':
+			p.pos++
+			s, err := strconv.Unquote(p.src[start:p.pos])
+			if err != nil {
+				return "", p.errorf("invalid string %s", p.src[start:p.pos])
+			}
+			return s, nil
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	end := strings.IndexAny(p.src[p.pos+1:], "'\n")
+	if end < 0 || p.src[p.pos+1+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.src[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return s, nil
+}
+
+// parseArray parses an array, which may span lines and end with a comma.
+func (p *tomlParser) parseArray() (any, error) {
+	p.pos++
+	arr := []any{}
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return arr, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+// parseInlineTable parses a single-line { key = value, ... } table.
+func (p *tomlParser) parseInlineTable() (any, error) {
+	p.pos++
+	table := map[string]any{}
+	p.skipBlank(false)
+	if !p.eof() && p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if p.eof() {
+			return nil, p.errorf("unterminated inline table")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
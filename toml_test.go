@@ -0,0 +1,124 @@
+package railflush
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // the result re-encoded as JSON
+	}{
+		{"empty", "# nothing here\n\n", `{}`},
+		{
+			"keys, strings and comments",
+			`api_token = "your-api-token-here" # a comment
+# a whole-line comment
+project_id = 'abc123'
+"quoted key" = "tab\there"
+'literal key' = 'C:\no\escapes'
+`,
+			`{"api_token":"your-api-token-here","literal key":"C:\\no\\escapes","project_id":"abc123","quoted key":"tab\there"}`,
+		},
+		{
+			"arrays",
+			`service_ids = ["service-id-1", "service-id-2"]
+environment_ids = [
+  "def456", # staging
+  "ghi789",
+]
+empty = []
+`,
+			`{"empty":[],"environment_ids":["def456","ghi789"],"service_ids":["service-id-1","service-id-2"]}`,
+		},
+		{"scalars", "a = true\nb = false\nc = 1_000\nd = +2.5\ne = -3e2\n", `{"a":true,"b":false,"c":1000,"d":2.5,"e":-3e2}`},
+		{
+			"tables",
+			`[environment_service_ids]
+staging = ["s1", "s2"]
+production = ["p1"]
+
+[service_timeouts]
+service-id-1 = "2m"
+`,
+			`{"environment_service_ids":{"production":["p1"],"staging":["s1","s2"]},"service_timeouts":{"service-id-1":"2m"}}`,
+		},
+		{"dotted keys", "service_timeouts.service-id-1 = \"2m\"\nservice_timeouts.\"id.2\" = \"30s\"\n", `{"service_timeouts":{"id.2":"30s","service-id-1":"2m"}}`},
+		{"inline table", `service_timeouts = { service-id-1 = "2m", service-id-2 = "30s" }`, `{"service_timeouts":{"service-id-1":"2m","service-id-2":"30s"}}`},
+		{"empty inline table", `service_timeouts = {}`, `{"service_timeouts":{}}`},
+		{
+			"arrays of tables",
+			`api_token = "tok"
+
+[[projects]]
+project_id = "abc123"
+environment_id = "def456"
+service_ids = ["s1"]
+
+[projects.environment_service_ids]
+staging = ["s2"]
+
+[[projects]]
+project_id = "xyz789"
+service_names = ["api"]
+
+[projects.environment_service_ids]
+production = ["p1"]
+`,
+			`{"api_token":"tok","projects":[{"environment_id":"def456","environment_service_ids":{"staging":["s2"]},"project_id":"abc123","service_ids":["s1"]},{"environment_service_ids":{"production":["p1"]},"project_id":"xyz789","service_names":["api"]}]}`,
+		},
+		{"CRLF line endings", "project_id = \"a\"\r\nservice_ids = [\"b\"]\r\n", `{"project_id":"a","service_ids":["b"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseTOML([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("parseTOML: %v", err)
+			}
+			got, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("parseTOML =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"duplicate key", "project_id = \"a\"\nproject_id = \"b\"\n", `line 2: duplicate key "project_id"`},
+		{"duplicate dotted key", "a.b = 1\na.b = 2\n", `line 2: duplicate key "a.b"`},
+		{"duplicate key in an inline table", `t = { a = 1, a = 2 }`, `line 1: duplicate key "a"`},
+		{"table defined twice", "[service_timeouts]\n[service_timeouts]\n", "line 2: table service_timeouts is defined twice"},
+		{"table over a value", "projects = 1\n[projects]\n", "line 2: projects is already defined and is not a table"},
+		{"array of tables over a table", "[projects]\n[[projects]]\n", "line 2: projects is already defined and is not an array of tables"},
+		{"multi-line basic string", `api_token = """tok"""`, "line 1: multi-line strings are not supported"},
+		{"multi-line literal string", "api_token = '''tok'''", "line 1: multi-line strings are not supported"},
+		{"date", "since = 2026-10-14T00:00:00Z", `line 1: unsupported value "2026-10-14T00:00:00Z"`},
+		{"bare word", "api_token = tok", `line 1: unsupported value "tok"`},
+		{"unterminated string", "api_token = \"tok\nproject_id = \"a\"\n", "line 1: unterminated string"},
+		{"unterminated array", "service_ids = [\"a\",\n", "line 2: unterminated array"},
+		{"missing comma in array", `service_ids = ["a" "b"]`, "line 1: expected ',' or ']' in array"},
+		{"unterminated inline table", "t = { a = 1", "line 1: unterminated inline table"},
+		{"two values on a line", `project_id = "a" environment_id = "b"`, "line 1: expected a newline after the value"},
+		{"missing equals", "project_id \"a\"", `line 1: expected "key = value"`},
+		{"unclosed header", "[[projects]\n", `line 1: expected "]]" after table name`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTOML([]byte(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseTOML error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package railflush
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLP span kinds and status codes, see opentelemetry-proto trace.proto.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	statusCodeError  = 2
+)
+
+// tracer collects spans for a run and exports them in one OTLP/HTTP JSON
+// request. Spans are only recorded when a tracer is attached to the context,
+// so tracing costs a context lookup per span when it is disabled.
+type tracer struct {
+	endpoint string
+	headers  map[string]string
+	traceID  string
+
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+type tracerKey struct{}
+
+type spanKey struct{}
+
+// newTracer returns a tracer exporting to endpoint, the full OTLP traces URL.
+func newTracer(endpoint string, headers map[string]string) *tracer {
+	return &tracer{endpoint: endpoint, headers: headers, traceID: randomID(16)}
+}
+
+// span is an in-progress span. A nil *span is a no-op.
+type span struct {
+	t    *tracer
+	data otlpSpan
+}
+
+// startSpan starts a span named name as a child of the span in ctx, if any.
+// It returns ctx unchanged and a nil span when tracing is disabled.
+func startSpan(ctx context.Context, name string, kind int, attrs ...otlpAttr) (context.Context, *span) {
+	t, _ := ctx.Value(tracerKey{}).(*tracer)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &span{t: t, data: otlpSpan{
+		TraceID:           t.traceID,
+		SpanID:            randomID(8),
+		Name:              name,
+		Kind:              kind,
+		StartTimeUnixNano: unixNano(time.Now()),
+		Attributes:        attrs,
+	}}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.data.ParentSpanID = parent.data.SpanID
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// setAttr adds a string attribute to the span.
+func (s *span) setAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	s.data.Attributes = append(s.data.Attributes, stringAttr(key, value))
+}
+
+// end finishes the span, marking it failed when err is set.
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+	now := unixNano(time.Now())
+	s.data.EndTimeUnixNano = now
+	if err != nil {
+		s.data.Status = &otlpStatus{Code: statusCodeError, Message: err.Error()}
+		s.data.Events = append(s.data.Events, otlpEvent{
+			TimeUnixNano: now,
+			Name:         "exception",
+			Attributes:   []otlpAttr{stringAttr("exception.message", err.Error())},
+		})
+	}
+	s.t.mu.Lock()
+	s.t.spans = append(s.t.spans, s.data)
+	s.t.mu.Unlock()
+}
+
+// export sends all ended spans to the collector.
+func (t *tracer) export(ctx context.Context, client *http.Client) error {
+	t.mu.Lock()
+	spans := t.spans
+	t.mu.Unlock()
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "railflush"
+	}
+	body, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttr{stringAttr("service.name", serviceName), stringAttr("service.version", version)}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/berry/railflush", Version: version},
+			Spans: spans,
+		}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("marshaling spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating trace export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting traces: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("exporting traces: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// otlpTracesEndpoint returns the OTLP/HTTP traces URL configured by the
+// standard OpenTelemetry environment variables, or "" when tracing is off.
+func otlpTracesEndpoint() string {
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		return strings.TrimRight(endpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// parseOTLPHeaders parses OTEL_EXPORTER_OTLP_HEADERS ("key=value,key2=value2").
+func parseOTLPHeaders(raw string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if k = strings.TrimSpace(k); ok && k != "" {
+			headers[k] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	for i := 0; i < n; i += 8 {
+		binary.BigEndian.PutUint64(b[i:], rand.Uint64())
+	}
+	return hex.EncodeToString(b)
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// The types below are the OTLP/JSON encoding of an ExportTraceServiceRequest.
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttr `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr  `json:"attributes,omitempty"`
+	Events            []otlpEvent `json:"events,omitempty"`
+	Status            *otlpStatus `json:"status,omitempty"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []otlpAttr `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string        `json:"key"`
+	Value otlpAttrValue `json:"value"`
+}
+
+type otlpAttrValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func stringAttr(key, value string) otlpAttr {
+	return otlpAttr{Key: key, Value: otlpAttrValue{StringValue: value}}
+}
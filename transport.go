@@ -0,0 +1,86 @@
+package railflush
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// defaultIdleConnTimeout matches the idle timeout of http.DefaultTransport.
+const defaultIdleConnTimeout = 90 * time.Second
+
+// newHTTPClient builds the HTTP client used for outbound requests other than
+// API calls, such as webhooks; newAPIHTTPClient builds the one for the API. The
+// proxy comes from -proxy when set, otherwise from the standard HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY environment variables.
+//
+// The default transport keeps only two idle connections per host, so with
+// more workers than that most requests would dial a new connection. Keeping
+// one idle connection per worker lets every worker reuse its connection.
+func newHTTPClient(cfg Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.Proxy != nil {
+		transport.Proxy = http.ProxyURL(cfg.Proxy)
+	}
+
+	idlePerHost := cfg.MaxIdleConnsPerHost
+	if idlePerHost == 0 {
+		idlePerHost = cfg.Concurrency
+	}
+	transport.DisableKeepAlives = false
+	transport.MaxIdleConnsPerHost = idlePerHost
+	transport.MaxIdleConns = max(transport.MaxIdleConns, idlePerHost)
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.HTTP2 || cfg.RootCAs != nil {
+		transport.TLSClientConfig = &tls.Config{RootCAs: cfg.RootCAs}
+	}
+	if cfg.HTTP2 {
+		// Offer only h2 during the TLS handshake, so servers cannot settle on
+		// HTTP/1.1; Client.send rejects API responses that still did.
+		transport.ForceAttemptHTTP2 = true
+		transport.TLSClientConfig.NextProtos = []string{"h2"}
+	}
+
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+	}
+}
+
+// newAPIHTTPClient builds the HTTP client for API calls: newHTTPClient's,
+// plus -insecure-skip-verify. Webhook URLs are secrets too, so their
+// certificates are verified regardless.
+func newAPIHTTPClient(cfg Config) *http.Client {
+	client := newHTTPClient(cfg)
+	if cfg.InsecureSkipVerify {
+		transport := client.Transport.(*http.Transport)
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	return client
+}
+
+// loadCertPool returns the system certificate pool with the PEM certificates
+// in path added, for -ca-cert.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,23 @@
+package railflush
+
+import "fmt"
+
+// Build metadata, set at build time with
+// -ldflags "-X github.com/berry/railflush.version=..." (likewise commit and
+// buildDate).
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// VersionString formats the build metadata for -version.
+func VersionString() string {
+	return fmt.Sprintf("railflush %s (commit %s, built %s)", version, commit, buildDate)
+}
+
+// DefaultUserAgent is the User-Agent sent to the Railway API unless
+// -user-agent overrides it.
+func DefaultUserAgent() string {
+	return "railflush/" + version
+}
@@ -0,0 +1,149 @@
+package railflush
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// A deployment's status is polled at an interval that starts at
+// waitPollInitial and doubles after every poll up to waitPollMax, so long
+// deploys do not hammer the API. They are variables so tests can poll faster.
+var (
+	waitPollInitial = 2 * time.Second
+	waitPollMax     = 30 * time.Second
+)
+
+// DeploymentFailedError reports a deployment that ended in a failed status
+// while being waited for.
+type DeploymentFailedError struct {
+	DeploymentID string
+	Status       string
+}
+
+func (e *DeploymentFailedError) Error() string {
+	return fmt.Sprintf("deployment %s ended with status %s", e.DeploymentID, e.Status)
+}
+
+// ErrRestartNotObserved is returned by -wait when a restarted deployment
+// stayed exactly as it was before the restart, suggesting that the API
+// accepted the restart without acting on it.
+var ErrRestartNotObserved = errors.New("restart not observed")
+
+// errWaitTimeout is the cancellation cause when -wait-timeout elapses.
+var errWaitTimeout = errors.New("wait timeout elapsed")
+
+// errInterrupted is the cancellation cause when the run's caller cancels it,
+// e.g. on SIGINT, while a deployment is being waited on.
+var errInterrupted = errors.New("interrupted")
+
+const queryDeploymentStatus = `
+query ($id: String!) {
+  deployment(id: $id) {
+    id
+    status
+    updatedAt
+  }
+}`
+
+// deploymentData represents the response from the single deployment query.
+type deploymentData struct {
+	Deployment Deployment `json:"deployment"`
+}
+
+// failedStatuses are deployment statuses from which a deployment will not
+// become healthy on its own.
+var failedStatuses = map[string]bool{
+	"CRASHED": true,
+	"FAILED":  true,
+	"REMOVED": true,
+}
+
+// DeploymentStatus fetches the current status of a deployment.
+func (c *Client) DeploymentStatus(ctx context.Context, deploymentID string) (string, error) {
+	dep, err := c.deploymentState(ctx, deploymentID)
+	return dep.Status, err
+}
+
+// deploymentState fetches a deployment's status and when it last changed.
+func (c *Client) deploymentState(ctx context.Context, deploymentID string) (Deployment, error) {
+	resp, err := c.do(ctx, queryDeploymentStatus, map[string]any{
+		"id": deploymentID,
+	})
+	if err != nil {
+		return Deployment{}, fmt.Errorf("querying deployment status: %w", err)
+	}
+
+	var data deploymentData
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return Deployment{}, fmt.Errorf("parsing deployment status: %w", err)
+	}
+	return data.Deployment, nil
+}
+
+// defaultHealthyStatuses are the statuses -wait stops at unless
+// -healthy-status says otherwise.
+var defaultHealthyStatuses = []string{"SUCCESS"}
+
+// waitForHealthy polls a deployment until it reaches one of healthy (SUCCESS
+// when empty), enters a failed status, or timeout elapses. timeout bounds only
+// the polling, starting after the restart; each poll is still bounded by
+// -timeout, and the whole wait by the run's -deadline if that comes first.
+//
+// When before is the deployment as it was just before a restart, a healthy
+// status only counts once its status or updatedAt has moved on from before, so
+// a restart the API accepted but never carried out is not mistaken for a
+// quick one; if nothing changes, ErrRestartNotObserved is returned.
+func waitForHealthy(ctx context.Context, c *Client, deploymentID string, healthy []string, timeout time.Duration, before Deployment) error {
+	if len(healthy) == 0 {
+		healthy = defaultHealthyStatuses
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, errWaitTimeout)
+	defer cancel()
+
+	// Without a timestamp to compare there is no telling a no-op apart.
+	changed := before.UpdatedAt.IsZero()
+	status := "unknown"
+	interval := waitPollInitial
+	for {
+		current, err := c.deploymentState(ctx, deploymentID)
+		if err == nil {
+			status = current.Status
+			changed = changed || status != before.Status || !current.UpdatedAt.Equal(before.UpdatedAt)
+		}
+		switch {
+		case err != nil && ctx.Err() == nil:
+			return err
+		case err != nil:
+		case slices.Contains(healthy, status) && changed:
+			return nil
+		case failedStatuses[status]:
+			return &DeploymentFailedError{DeploymentID: deploymentID, Status: status}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(context.Cause(ctx), errInterrupted) {
+				return fmt.Errorf("interrupted while waiting for deployment %s to become healthy (last status %s)", deploymentID, status)
+			}
+			if !changed && slices.Contains(healthy, status) {
+				return fmt.Errorf("%w: deployment %s is still %s and unchanged since before the restart (updated %s); the API accepted the restart, but it may not have happened",
+					ErrRestartNotObserved, deploymentID, status, before.UpdatedAt.Format(time.RFC3339))
+			}
+			switch cause := context.Cause(ctx); {
+			case errors.Is(cause, errWaitTimeout):
+				return fmt.Errorf("deployment %s did not become healthy in time (-wait-timeout %s, last status %s)", deploymentID, timeout, status)
+			case errors.Is(cause, context.DeadlineExceeded):
+				return fmt.Errorf("deployment %s did not become healthy before the run's -deadline (last status %s)", deploymentID, status)
+			}
+			return fmt.Errorf("waiting for deployment %s (last status %s): %w", deploymentID, status, ctx.Err())
+		case <-timer.C:
+		}
+		interval = min(interval*2, waitPollMax)
+	}
+}
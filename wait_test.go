@@ -0,0 +1,100 @@
+package railflush
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fastPolls shortens the -wait poll interval for the rest of the test.
+func fastPolls(t *testing.T) {
+	t.Helper()
+	initial, maxInterval := waitPollInitial, waitPollMax
+	waitPollInitial, waitPollMax = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { waitPollInitial, waitPollMax = initial, maxInterval })
+}
+
+func TestWaitForHealthy(t *testing.T) {
+	fastPolls(t)
+	updated := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	before := Deployment{ID: "dep-a", Status: "SUCCESS", UpdatedAt: updated}
+	tests := []struct {
+		name      string
+		status    func(id string, n int) Deployment
+		wantErr   error  // matched with errors.Is
+		wantMsg   string // contained in the error
+		wantPolls int    // 0 to skip the check
+	}{
+		{
+			name: "restarts then goes healthy",
+			status: func(id string, n int) Deployment {
+				switch {
+				case n == 1:
+					return before
+				case n < 4:
+					return Deployment{ID: id, Status: "DEPLOYING", UpdatedAt: updated.Add(time.Minute)}
+				}
+				return Deployment{ID: id, Status: "SUCCESS", UpdatedAt: updated.Add(2 * time.Minute)}
+			},
+			wantPolls: 4,
+		},
+		{
+			name:    "never changes",
+			status:  func(string, int) Deployment { return before },
+			wantErr: ErrRestartNotObserved,
+			wantMsg: "unchanged since before the restart",
+		},
+		{
+			name: "crashes",
+			status: func(id string, n int) Deployment {
+				return Deployment{ID: id, Status: "CRASHED", UpdatedAt: updated.Add(time.Minute)}
+			},
+			wantMsg:   "deployment dep-a ended with status CRASHED",
+			wantPolls: 1,
+		},
+		{
+			name: "stays deploying",
+			status: func(id string, n int) Deployment {
+				return Deployment{ID: id, Status: "DEPLOYING", UpdatedAt: updated.Add(time.Minute)}
+			},
+			wantMsg: "did not become healthy in time (-wait-timeout 100ms, last status DEPLOYING)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t, nil)
+			api.status = tt.status
+			err := waitForHealthy(context.Background(), api.client(), "dep-a", nil, 100*time.Millisecond, before)
+			switch {
+			case tt.wantErr == nil && tt.wantMsg == "":
+				if err != nil {
+					t.Errorf("waitForHealthy: %v", err)
+				}
+			case err == nil:
+				t.Errorf("waitForHealthy succeeded, want an error")
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("waitForHealthy = %v, want %v", err, tt.wantErr)
+			case !strings.Contains(err.Error(), tt.wantMsg):
+				t.Errorf("waitForHealthy = %v, want one containing %q", err, tt.wantMsg)
+			}
+			if n := api.count("deployment("); tt.wantPolls != 0 && n != tt.wantPolls {
+				t.Errorf("got %d polls, want %d", n, tt.wantPolls)
+			}
+		})
+	}
+}
+
+func TestWaitForHealthyInterrupted(t *testing.T) {
+	fastPolls(t)
+	api := newFakeAPI(t, nil)
+	api.status = func(id string, n int) Deployment { return Deployment{ID: id, Status: "DEPLOYING"} }
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(20*time.Millisecond, func() { cancel(errInterrupted) })
+
+	err := waitForHealthy(ctx, api.client(), "dep-a", nil, time.Minute, Deployment{})
+	if err == nil || !strings.Contains(err.Error(), "interrupted while waiting for deployment dep-a") {
+		t.Errorf("waitForHealthy = %v, want an interruption error", err)
+	}
+}
@@ -0,0 +1,120 @@
+package railflush
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// webhookTemplates are the built-in templates selectable by name with
+// -webhook-template.
+var webhookTemplates = map[string]string{
+	// default posts the same object as -output json.
+	"default": `{{json .}}`,
+	// text suits chat tools that accept {"text": ...}, such as Mattermost
+	// or Google Chat.
+	"text": `{"text": {{json (printf "railflush %s: %d succeeded, %d failed, %d skipped (%dms)" .Action .Succeeded .Failed .Skipped .ElapsedMS)}}}`,
+	// pagerduty sends a PagerDuty Events API v2 event that is triggered when
+	// services failed or were skipped, and resolved otherwise.
+	"pagerduty": `{
+  "routing_key": {{json (env "PAGERDUTY_ROUTING_KEY")}},
+  "event_action": {{if or .Failed .Skipped}}"trigger"{{else}}"resolve"{{end}},
+  "dedup_key": {{json (printf "railflush-%s" .Action)}},
+  "payload": {
+    "summary": {{json (printf "railflush %s: %d failed, %d skipped" .Action .Failed .Skipped)}},
+    "source": "railflush",
+    "severity": {{if .Failed}}"error"{{else}}"warning"{{end}},
+    "custom_details": {{json .}}
+  }
+}`,
+}
+
+// webhookFuncs are the functions available to webhook templates.
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"env":  os.Getenv,
+	"join": strings.Join,
+	"failures": func(s Summary) []ServiceResult {
+		var failed []ServiceResult
+		for _, r := range s.Services {
+			if r.Status == StatusFailed {
+				failed = append(failed, r)
+			}
+		}
+		return failed
+	},
+}
+
+// parseWebhookTemplate parses -webhook-template: the name of a built-in
+// template, "@path" to read one from a file, or the template text itself. The
+// template is rendered once against a sample summary, so mistakes surface at
+// startup rather than after the restarts.
+func parseWebhookTemplate(spec string) (*template.Template, error) {
+	text, ok := webhookTemplates[spec]
+	if !ok && strings.HasPrefix(spec, "@") {
+		data, err := os.ReadFile(spec[1:])
+		if err != nil {
+			return nil, fmt.Errorf("reading webhook template: %w", err)
+		}
+		text, ok = string(data), true
+	}
+	if !ok {
+		text = spec
+	}
+
+	tmpl, err := template.New("webhook").Funcs(webhookFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook template: %w", err)
+	}
+	sample := Summary{
+		Action: "restart",
+		Services: []ServiceResult{
+			{ServiceID: "svc-1", ProjectID: "project", EnvironmentID: "env", DeploymentID: "dep-1", Action: "restart", Status: "restarted"},
+			{ServiceID: "svc-2", ProjectID: "project", EnvironmentID: "env", Action: "restart", Status: StatusFailed, Error: "sample error"},
+		},
+		Projects: []ProjectSummary{{ProjectID: "project", Counts: Counts{Succeeded: 1, Failed: 1}}},
+		Counts:   Counts{Succeeded: 1, Failed: 1},
+	}
+	if _, err := renderWebhook(tmpl, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderWebhook executes tmpl against report and checks that the result is
+// valid JSON.
+func renderWebhook(tmpl *template.Template, report Summary) (json.RawMessage, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, report); err != nil {
+		return nil, fmt.Errorf("rendering webhook template: %w", err)
+	}
+	if !json.Valid(b.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not render valid JSON: %.200s", b.String())
+	}
+	return b.Bytes(), nil
+}
+
+// webhookNotifier posts a templated JSON payload to an arbitrary endpoint.
+type webhookNotifier struct {
+	url  string
+	tmpl *template.Template
+}
+
+func (webhookNotifier) name() string      { return "Webhook" }
+func (n webhookNotifier) webhook() string { return n.url }
+
+// payload renders the template, or the default one when Config was built
+// without LoadConfig and has no template.
+func (n webhookNotifier) payload(s runSummary) (any, error) {
+	tmpl := n.tmpl
+	if tmpl == nil {
+		tmpl = template.Must(template.New("webhook").Funcs(webhookFuncs).Parse(webhookTemplates["default"]))
+	}
+	return renderWebhook(tmpl, s.Report)
+}
@@ -0,0 +1,127 @@
+package railflush
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // the result re-encoded as JSON
+	}{
+		{"empty", "# nothing here\n\n", `{}`},
+		{
+			"block sequence and comments",
+			`---
+api_token: tok # a comment
+project_id: abc123
+# a whole-line comment
+service_ids:
+  - service-id-1
+  - service-id-2
+`,
+			`{"api_token":"tok","project_id":"abc123","service_ids":["service-id-1","service-id-2"]}`,
+		},
+		{"sequence at the key's indentation", "service_ids:\n- a\n- b\n", `{"service_ids":["a","b"]}`},
+		{"flow sequence", `environment_ids: [def456, "a,b", 'c', "d # e"]`, `{"environment_ids":["def456","a,b","c","d # e"]}`},
+		{"empty flow sequence", "service_ids: []", `{"service_ids":[]}`},
+		{"empty mapping", "service_timeouts: {}", `{"service_timeouts":{}}`},
+		{
+			"nested mapping",
+			`environment_service_ids:
+  staging: [s1, s2]
+  production:
+    - p1
+service_timeouts:
+  service-id-1: 2m
+`,
+			`{"environment_service_ids":{"production":["p1"],"staging":["s1","s2"]},"service_timeouts":{"service-id-1":"2m"}}`,
+		},
+		{
+			"- key: mappings",
+			`projects:
+  - project_id: abc123
+    environment_id: def456
+    service_ids: [s1]
+  - project_id: xyz789
+    service_names:
+      - api
+  -
+    project_id: nested
+`,
+			`{"projects":[{"environment_id":"def456","project_id":"abc123","service_ids":["s1"]},{"project_id":"xyz789","service_names":["api"]},{"project_id":"nested"}]}`,
+		},
+		{
+			"quoting",
+			`"quoted key": "tab\there"
+'single': 'it''s'
+hash: "a # b"
+plain: a#b
+colon: "x: y"
+`,
+			`{"colon":"x: y","hash":"a # b","plain":"a#b","quoted key":"tab\there","single":"it's"}`,
+		},
+		{
+			"scalars",
+			`a: ~
+b: null
+c:
+d: true
+e: False
+f: 12
+g: -1.5e3
+h: 012
+i: "12"
+`,
+			`{"a":null,"b":null,"c":null,"d":true,"e":false,"f":12,"g":-1.5e3,"h":"012","i":"12"}`,
+		},
+		{"top-level sequence", "- a\n- b: c\n", `["a",{"b":"c"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseYAML([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("parseYAML: %v", err)
+			}
+			got, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("parseYAML =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"duplicate key", "project_id: a\nproject_id: b\n", `line 2: duplicate key "project_id"`},
+		{"duplicate key in a sequence item", "projects:\n  - project_id: a\n    project_id: b\n", `line 3: duplicate key "project_id"`},
+		{"tab indentation", "service_ids:\n\t- a\n", "line 2: tabs are not allowed for indentation"},
+		{"flow mapping", "service_timeouts: {a: 1m}", "line 1: flow mappings are not supported"},
+		{"literal block scalar", "api_token: |\n  tok\n", "line 1: block scalars are not supported"},
+		{"folded block scalar", "api_token: >-\n  tok\n", "line 1: block scalars are not supported"},
+		{"unterminated flow sequence", "service_ids: [a, b", "line 1: unterminated flow sequence"},
+		{"bad double-quoted string", `api_token: "a\q"`, "line 1: invalid double-quoted string"},
+		{"bad single-quoted string", "api_token: 'abc", "line 1: invalid single-quoted string"},
+		{"unexpected indentation", "project_id: a\n  environment_id: b\n", "line 2: unexpected indentation"},
+		{"not a key", "project_id: a\njust text\n", `line 2: expected "key: value"`},
+		{"sequence item in a mapping", "project_id: a\n- b\n", "line 2: unexpected sequence item"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML([]byte(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseYAML error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}